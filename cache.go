@@ -0,0 +1,116 @@
+package jsonpath
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheSize is the capacity of DefaultCache
+const DefaultCacheSize = 512
+
+// DefaultCache keeps compiled paths for functions taking path as a string,
+// like JsonPathLookup, Set, Del and Append.
+var DefaultCache = NewCache(DefaultCacheSize)
+
+// Cache is a bounded LRU cache of compiled paths, safe for concurrent use.
+// Compiled paths are immutable, so the same *Compiled is shared by all callers.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	hits     uint64
+	misses   uint64
+}
+
+// CacheStats contains statistics of the cache
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Size     int
+	Capacity int
+}
+
+type cacheEntry struct {
+	path string
+	c    *Compiled
+}
+
+// NewCache creates cache holding up to capacity paths, caching is disabled when capacity <= 0
+func NewCache(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Compile returns cached compiled path or compiles and stores it.
+// Paths which could not be compiled are not cached.
+func (cache *Cache) Compile(jpath string) (*Compiled, error) {
+	cache.mu.Lock()
+	if e, ok := cache.items[jpath]; ok {
+		cache.order.MoveToFront(e)
+		cache.hits++
+		cache.mu.Unlock()
+		return e.Value.(*cacheEntry).c, nil
+	}
+	cache.misses++
+	cache.mu.Unlock()
+
+	// compile without lock, concurrent misses of the same path just compile it twice
+	c, err := Compile(jpath)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.capacity <= 0 {
+		return c, nil
+	}
+	if e, ok := cache.items[jpath]; ok {
+		cache.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).c, nil
+	}
+	cache.items[jpath] = cache.order.PushFront(&cacheEntry{path: jpath, c: c})
+	cache.evict()
+	return c, nil
+}
+
+// Resize changes capacity of the cache, the least recently used paths are dropped
+func (cache *Cache) Resize(capacity int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.capacity = capacity
+	cache.evict()
+}
+
+// Stats returns hit and miss counters and the current size of the cache
+func (cache *Cache) Stats() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return CacheStats{
+		Hits:     cache.hits,
+		Misses:   cache.misses,
+		Size:     cache.order.Len(),
+		Capacity: cache.capacity,
+	}
+}
+
+// Reset drops all cached paths and statistics
+func (cache *Cache) Reset() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.items = map[string]*list.Element{}
+	cache.order.Init()
+	cache.hits, cache.misses = 0, 0
+}
+
+func (cache *Cache) evict() {
+	for cache.order.Len() > 0 && cache.order.Len() > cache.capacity {
+		e := cache.order.Back()
+		cache.order.Remove(e)
+		delete(cache.items, e.Value.(*cacheEntry).path)
+	}
+}
@@ -0,0 +1,115 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func Test_Cache(t *testing.T) {
+	cache := NewCache(2)
+	a, err := cache.Compile("$.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := cache.Compile("$.a"); c != a {
+		t.Error("cached path was compiled again")
+	}
+	cache.Compile("$.b")
+	// $.a is used recently, so $.b is evicted
+	cache.Compile("$.a")
+	cache.Compile("$.c")
+	if c, _ := cache.Compile("$.a"); c != a {
+		t.Error("recently used path was evicted")
+	}
+	expected := CacheStats{Hits: 3, Misses: 3, Size: 2, Capacity: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+	cache.Compile("$.b")
+	if stats := cache.Stats(); stats.Misses != 4 {
+		t.Errorf("evicted path should be compiled again: %+v", stats)
+	}
+
+	if _, err := cache.Compile("$.store[?]"); err == nil {
+		t.Error("expected compile error")
+	}
+	if stats := cache.Stats(); stats.Size != 2 {
+		t.Errorf("invalid path should not be cached: %+v", stats)
+	}
+
+	cache.Resize(1)
+	if stats := cache.Stats(); stats.Size != 1 || stats.Capacity != 1 {
+		t.Errorf("unexpected stats after resize: %+v", stats)
+	}
+	cache.Reset()
+	if stats := cache.Stats(); stats != (CacheStats{Capacity: 1}) {
+		t.Errorf("unexpected stats after reset: %+v", stats)
+	}
+}
+
+func Test_CacheDisabled(t *testing.T) {
+	cache := NewCache(0)
+	a, _ := cache.Compile("$.a")
+	b, _ := cache.Compile("$.a")
+	if a == b {
+		t.Error("path was cached")
+	}
+	if stats := cache.Stats(); stats.Size != 0 || stats.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func Test_CacheConcurrent(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"items": [{"id": 1, "tags": ["a"]}, {"id": 2, "tags": ["b"]}, {"id": 3, "tags": ["c"]}]}`), &data)
+	shared := MustCompile("$.items[?(@.id > 1)].id")
+	cache := NewCache(4)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				res, err := shared.Lookup(data)
+				if err != nil || fmt.Sprint(res) != "[2 3]" {
+					t.Errorf("unexpected result: %v, %v", res, err)
+					return
+				}
+				// more paths than capacity to force eviction
+				c, err := cache.Compile(fmt.Sprintf("$.items[%d].id", i%6-3))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := c.Lookup(data); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := JsonPathLookup(data, "$.items[?(@.id == 2)].tags"); err != nil {
+					t.Error(err)
+					return
+				}
+				doc := map[string]interface{}{"items": []interface{}{}}
+				if err := Append(doc, "$.items", g); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := Set(doc, "$.items[0]", i); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := Del(doc, "$.items[0]"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if stats := cache.Stats(); stats.Hits+stats.Misses != 8*200 || stats.Size > 4 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineReader reads lines typed by user
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// scanReader reads lines of input which is not a terminal, like pipes and files
+type scanReader struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func (s *scanReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	if !s.in.Scan() {
+		if err := s.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.in.Text(), nil
+}
+
+// Keys handled by lineEditor
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCR        = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// lineEditor reads lines from terminal in raw mode and edits them at the cursor:
+// Tab completes the key before the cursor, Up and Down walk through history,
+// Left, Right, Home, End, Backspace, Delete, Ctrl-A, Ctrl-E, Ctrl-K and Ctrl-U move and delete,
+// Ctrl-C drops the line and Ctrl-D on empty line ends input.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// complete returns lines completing the key at the end of line
+	complete func(line string) ([]string, error)
+	// history returns lines of history, the last one is the newest
+	history func() []string
+
+	prompt string
+	line   []rune
+	pos    int
+}
+
+func newLineEditor(in io.Reader, out io.Writer, complete func(string) ([]string, error), history func() []string) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, complete: complete, history: history}
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.prompt, e.line, e.pos = prompt, nil, 0
+	// histIdx is the shown line of history, len(history) for the edited line
+	history := e.history()
+	histIdx := len(history)
+	var edited []rune
+	e.redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(e.line) > 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return string(e.line), nil
+			}
+			return "", err
+		}
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", nil
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyTab:
+			e.completeAtCursor()
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = append([]rune{}, e.line[e.pos:]...)
+			e.pos = 0
+		case keyEscape:
+			switch e.readEscape() {
+			case "A":
+				if histIdx > 0 {
+					if histIdx == len(history) {
+						edited = e.line
+					}
+					histIdx--
+					e.setLine(history[histIdx])
+				}
+			case "B":
+				if histIdx < len(history) {
+					histIdx++
+					if histIdx == len(history) {
+						e.line, e.pos = edited, len(edited)
+					} else {
+						e.setLine(history[histIdx])
+					}
+				}
+			case "C":
+				if e.pos < len(e.line) {
+					e.pos++
+				}
+			case "D":
+				if e.pos > 0 {
+					e.pos--
+				}
+			case "H", "1~", "7~":
+				e.pos = 0
+			case "F", "4~", "8~":
+				e.pos = len(e.line)
+			case "3~":
+				e.deleteAt(e.pos)
+			}
+		default:
+			if r < ' ' {
+				continue
+			}
+			e.insert([]rune{r})
+		}
+		e.redraw()
+	}
+}
+
+// readEscape reads the rest of escape sequence, like [A for Up, and returns it without [ or O
+func (e *lineEditor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return ""
+	}
+	var seq strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		// parameters are digits and semicolons, the final byte ends the sequence
+		if r >= 0x40 && r <= 0x7e {
+			return seq.String()
+		}
+	}
+}
+
+// completeAtCursor replaces the line before the cursor by its single completion
+// or by the common prefix of completions, other completions are listed below the line
+func (e *lineEditor) completeAtCursor() {
+	head := string(e.line[:e.pos])
+	candidates, err := e.complete(head)
+	if err != nil || len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		prefix = commonPrefix(prefix, c)
+	}
+	if len(candidates) == 1 || len(prefix) > len(head) && strings.HasPrefix(prefix, head) {
+		tail := e.line[e.pos:]
+		e.line = append([]rune(prefix), tail...)
+		e.pos = len(e.line) - len(tail)
+		return
+	}
+	fmt.Fprint(e.out, "\r\n")
+	for _, c := range candidates {
+		fmt.Fprintf(e.out, "%s\r\n", c)
+	}
+}
+
+func (e *lineEditor) setLine(line string) {
+	e.line = []rune(line)
+	e.pos = len(e.line)
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.pos]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.pos:]...)
+	e.pos += len(runes)
+}
+
+func (e *lineEditor) deleteAt(pos int) {
+	if pos < len(e.line) {
+		e.line = append(e.line[:pos:pos], e.line[pos+1:]...)
+	}
+}
+
+// redraw prints prompt and line from the start of terminal line and moves cursor to its position
+func (e *lineEditor) redraw() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// commonPrefix returns the longest common prefix of a and b, which is valid UTF-8
+func commonPrefix(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	i := 0
+	for i < len(ra) && i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	return string(ra[:i])
+}
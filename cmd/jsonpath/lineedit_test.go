@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_lineEditor(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(replTestDoc), &doc)
+	complete := func(line string) ([]string, error) {
+		return completions(doc, line)
+	}
+	history := func() []string {
+		return []string{"$.a", "$.b"}
+	}
+	tcases := []struct {
+		input string
+		line  string
+	}{
+		{"$.st\t.bo\t\r", "$.store.book"},
+		{"$.store.b\to\t\r", "$.store.book"},
+		{"$.st.book\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\t\r", "$.store.book"},
+		{"$.store.book[0].t\t\n", "$.store.book[0].title"},
+		{"$.store.x\t\r", "$.store.x"},
+		{"$.bc\x1b[D\x1b[Da\r", "$.abc"},
+		{"$.abc\x1b[H\x1b[3~\x1b[F\x7f\r", ".ab"},
+		{"$.abc\x01\x1b[C\x0b\r", "$"},
+		{"$.abc\x1b[D\x15\r", "c"},
+		{"x\x1b[A\x1b[A\x1b[B\r", "$.b"},
+		{"x\x1b[A\x1b[B\r", "x"},
+		{"x\x1b[A\x1b[A\x1b[A\r", "$.a"},
+		{"$.ключ\x1b[D\x7f\r", "$.клч"},
+		{"$.a\x03", ""},
+		{"$.a", "$.a"},
+	}
+	for _, tcase := range tcases {
+		var out bytes.Buffer
+		e := newLineEditor(strings.NewReader(tcase.input), &out, complete, history)
+		line, err := e.readLine("> ")
+		if err != nil || line != tcase.line {
+			t.Errorf("%q: expected %q, got %q, %v", tcase.input, tcase.line, line, err)
+		}
+	}
+
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader("$.store.b\t\x04"), &out, complete, history)
+	if _, err := e.readLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\r\n$.store.bicycle\r\n$.store.book\r\n") {
+		t.Errorf("completions were not listed: %q", out.String())
+	}
+	e = newLineEditor(strings.NewReader("\x04"), &out, complete, history)
+	if _, err := e.readLine("> "); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func Test_replTerminal(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(replTestDoc), &doc)
+	var out bytes.Buffer
+	r := newREPL(doc, nil, &out)
+	r.in = newLineEditor(strings.NewReader("$.store.bi\t.color\r\x1b[A\r\x04"), &out, func(line string) ([]string, error) {
+		return completions(doc, line)
+	}, func() []string {
+		return r.history
+	})
+	if err := r.run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "$['store']['bicycle']['color']\t\"red\"\n"); n != 2 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,395 @@
+// Command jsonpath evaluates JSONPath expressions against JSON documents.
+//
+// Usage:
+//
+//	jsonpath [flags] path [file...]
+//	jsonpath [flags] -e path [-e path...] [file...]
+//
+// Documents are read from files or from stdin when no files are given, every file
+// may contain a single JSON document or several of them, like newline-delimited JSON.
+// Results of every path are printed for every document in the format chosen by -o:
+//
+//	json  results encoded as JSON, one per line (default)
+//	raw   like json, but strings are printed without quotes
+//	path  normalized path and JSON encoded value of every matched node, separated by tab
+//
+// All formats print the same matched nodes, json and raw print the value of a single
+// matched node and the list of values when several nodes matched.
+//
+// Documents are modified by -set, -append and -del flags in order they are given
+// before paths are evaluated, without paths the modified documents are printed.
+// Values of -set and -append are parsed as JSON, values which are not valid JSON are strings:
+//
+//	jsonpath -set '$.name=bob' -append '$.tags=["new"]' -del '$.draft' doc.json
+//
+// Exit status is 0 when any path matched, 1 when nothing matched and 2 on errors.
+//
+// With -i the document is loaded once and paths typed on stdin are evaluated interactively,
+// see :help for commands. In terminal Tab completes the key before the cursor and Up and Down
+// walk through history, which is kept in the file given by -history.
+//
+//	jsonpath -i -history ~/.jsonpath_history doc.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ilyaferilo/jsonpath"
+)
+
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// pathsFlag collects repeated -e flags
+type pathsFlag []string
+
+func (f *pathsFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *pathsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// edit is a modification of document requested by -set, -append or -del
+type edit struct {
+	op    string
+	path  string
+	value interface{}
+}
+
+func (e edit) apply(doc *interface{}) error {
+	switch e.op {
+	case "set":
+		return jsonpath.Set(doc, e.path, e.value)
+	case "append":
+		return jsonpath.Append(doc, e.path, e.value)
+	case "del":
+		return jsonpath.Del(doc, e.path)
+	}
+	return fmt.Errorf("unknown edit %s", e.op)
+}
+
+// editsFlag adds edits of single kind to the list shared by all edit flags,
+// so edits are applied in command line order
+type editsFlag struct {
+	op    string
+	edits *[]edit
+}
+
+func (f editsFlag) String() string {
+	return ""
+}
+
+func (f editsFlag) Set(s string) error {
+	e := edit{op: f.op, path: s}
+	if f.op != "del" {
+		path, value, ok := splitAssignment(s)
+		if !ok {
+			return fmt.Errorf("expected path=value, got %q", s)
+		}
+		e.path, e.value = path, parseValue(value)
+	}
+	if _, err := jsonpath.Compile(e.path); err != nil {
+		return err
+	}
+	*f.edits = append(*f.edits, e)
+	return nil
+}
+
+// splitAssignment splits path=value at the first '=' outside of brackets and quotes,
+// so filters like [?(@.a == 1)] may be used in path
+func splitAssignment(s string) (path, value string, ok bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '=' && depth == 0:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// parseValue decodes JSON value, invalid JSON is returned as string
+func parseValue(s string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return s
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return s
+	}
+	return v
+}
+
+type command struct {
+	paths   []*jsonpath.Compiled
+	edits   []edit
+	format  string
+	out     *bufio.Writer
+	enc     *json.Encoder
+	matched bool
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cmd := &command{}
+	var paths pathsFlag
+	var interactive bool
+	var history string
+
+	flags := flag.NewFlagSet("jsonpath", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jsonpath [flags] path [file...]")
+		fmt.Fprintln(stderr, "       jsonpath [flags] -e path [-e path...] [file...]")
+		fmt.Fprintln(stderr, "       jsonpath -i [flags] file")
+		flags.PrintDefaults()
+	}
+	flags.Var(&paths, "e", "`path` to evaluate, may be repeated")
+	flags.StringVar(&cmd.format, "o", "json", "output `format`: json, raw or path")
+	flags.Var(editsFlag{op: "set", edits: &cmd.edits}, "set", "set `path=value` before evaluation, may be repeated")
+	flags.Var(editsFlag{op: "append", edits: &cmd.edits}, "append", "append `path=value` before evaluation, may be repeated")
+	flags.Var(editsFlag{op: "del", edits: &cmd.edits}, "del", "delete `path` before evaluation, may be repeated")
+	flags.BoolVar(&interactive, "i", false, "evaluate paths read from stdin against the document interactively")
+	flags.StringVar(&history, "history", "", "keep history of interactive mode in `file`")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if interactive {
+		return cmd.interactive(flags.Args(), history, stdin, stdout, stderr)
+	}
+	switch cmd.format {
+	case "json", "raw", "path":
+	default:
+		fmt.Fprintf(stderr, "jsonpath: unknown output format %q\n", cmd.format)
+		return exitError
+	}
+
+	files := flags.Args()
+	if len(paths) == 0 && len(cmd.edits) == 0 {
+		if len(files) == 0 {
+			flags.Usage()
+			return exitError
+		}
+		paths, files = files[:1], files[1:]
+	}
+	for _, path := range paths {
+		c, err := jsonpath.Compile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+			return exitError
+		}
+		cmd.paths = append(cmd.paths, c)
+	}
+
+	cmd.out = bufio.NewWriter(stdout)
+	defer cmd.out.Flush()
+	cmd.enc = json.NewEncoder(cmd.out)
+	cmd.enc.SetEscapeHTML(false)
+
+	failed := false
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := cmd.processFile(name, stdin); err != nil {
+			fmt.Fprintf(stderr, "jsonpath: %s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if err := cmd.out.Flush(); err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitError
+	}
+	switch {
+	case failed:
+		return exitError
+	case cmd.matched:
+		return exitMatch
+	}
+	return exitNoMatch
+}
+
+func (cmd *command) processFile(name string, stdin io.Reader) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := cmd.process(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// interactive runs REPL against the first document of file
+func (cmd *command) interactive(files []string, history string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(files) != 1 {
+		fmt.Fprintln(stderr, "jsonpath: interactive mode needs a single document file, stdin is used for input")
+		return exitError
+	}
+	doc, err := cmd.load(files[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %s: %v\n", files[0], err)
+		return exitError
+	}
+	r := newREPL(doc, stdin, stdout)
+	if f, ok := stdin.(*os.File); ok {
+		if restore, ok := r.useTerminal(f); ok {
+			defer restore()
+		}
+	}
+	if history != "" {
+		if err := r.openHistory(history); err != nil {
+			fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+			return exitError
+		}
+	}
+	err = r.run()
+	if closeErr := r.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitError
+	}
+	return exitMatch
+}
+
+// load reads the first document of file and modifies it
+func (cmd *command) load(name string) (interface{}, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, cmd.edit(&doc)
+}
+
+// edit applies edits to document in command line order
+func (cmd *command) edit(doc *interface{}) error {
+	for _, e := range cmd.edits {
+		if err := e.apply(doc); err != nil {
+			return fmt.Errorf("%s %s: %v", e.op, e.path, err)
+		}
+	}
+	return nil
+}
+
+// process modifies document and prints results of every path
+func (cmd *command) process(doc interface{}) error {
+	if err := cmd.edit(&doc); err != nil {
+		return err
+	}
+	if len(cmd.paths) == 0 {
+		cmd.matched = true
+		if cmd.format == "path" {
+			return cmd.printNode("$", doc)
+		}
+		return cmd.print(doc)
+	}
+	for _, c := range cmd.paths {
+		if err := cmd.evaluate(c, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate prints nodes matched by path, every format prints the same nodes:
+// path prints every node, json and raw print the value of a single matched node
+// and the list of values when several nodes matched
+func (cmd *command) evaluate(c *jsonpath.Compiled, doc interface{}) error {
+	var values []interface{}
+	var printErr error
+	err := c.Each(doc, func(n jsonpath.Node) bool {
+		values = append(values, n.Value)
+		if cmd.format == "path" {
+			printErr = cmd.printNode(n.Path(), n.Value)
+		}
+		return printErr == nil
+	})
+	if len(values) > 0 {
+		cmd.matched = true
+	}
+	// missing keys, out of range indexes and nulls are reported as no match
+	if err != nil && !jsonpath.IsNotFound(err) {
+		return err
+	}
+	if printErr != nil || cmd.format == "path" || len(values) == 0 {
+		return printErr
+	}
+	if len(values) == 1 {
+		return cmd.print(values[0])
+	}
+	return cmd.print(values)
+}
+
+func (cmd *command) print(v interface{}) error {
+	if s, ok := v.(string); ok && cmd.format == "raw" {
+		cmd.out.WriteString(s)
+		return cmd.out.WriteByte('\n')
+	}
+	return cmd.encode(v)
+}
+
+func (cmd *command) printNode(path string, v interface{}) error {
+	cmd.out.WriteString(path)
+	cmd.out.WriteByte('\t')
+	return cmd.encode(v)
+}
+
+func (cmd *command) encode(v interface{}) error {
+	return cmd.enc.Encode(v)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	input := `{"name": "a", "tags": ["x", "y"], "n": 12345678901234567890, "items": [{"id": 1}, {"id": 2}]}
+{"name": "b", "tags": [], "n": 1.5, "items": []}
+`
+	tcases := []struct {
+		args   []string
+		output string
+		code   int
+	}{
+		{[]string{"$.name"}, "\"a\"\n\"b\"\n", exitMatch},
+		{[]string{"-o", "raw", "$.name"}, "a\nb\n", exitMatch},
+		{[]string{"$.n"}, "12345678901234567890\n1.5\n", exitMatch},
+		{[]string{"$.tags[*]"}, "[\"x\",\"y\"]\n", exitMatch},
+		{[]string{"-o", "path", "$.tags[*]"}, "$['tags'][0]\t\"x\"\n$['tags'][1]\t\"y\"\n", exitMatch},
+		{[]string{"-e", "$.name", "-e", "$.tags[0]"}, "\"a\"\n\"x\"\n\"b\"\n", exitMatch},
+		{[]string{"$.missing"}, "", exitNoMatch},
+		{[]string{"$.items[?(@.id > 5)]"}, "", exitNoMatch},
+		{[]string{"-o", "path", "$.items[?(@.id > 1)].id"}, "$['items'][1]['id']\t2\n", exitMatch},
+		{[]string{"$.name["}, "", exitError},
+		{[]string{"-o", "yaml", "$.name"}, "", exitError},
+		{[]string{}, "", exitError},
+		{
+			[]string{"-set", "$.name=c", "-set", "$.items[?(@.id == 2)].id={\"a\": 1}", "-del", "$.n", "-del", "$.tags"},
+			"{\"items\":[{\"id\":1},{\"id\":{\"a\":1}}],\"name\":\"c\"}\n{\"items\":[],\"name\":\"c\"}\n",
+			exitMatch,
+		},
+		{[]string{"-append", "$.tags=\"z\"", "-e", "$.tags"}, "[\"x\",\"y\",\"z\"]\n[\"z\"]\n", exitMatch},
+		{[]string{"-del", "$.tags", "-append", "$.tags=[1]", "-o", "raw", "-e", "$.tags"}, "[1]\n[1]\n", exitMatch},
+		{[]string{"-set", "$.name"}, "", exitError},
+		{[]string{"-del", "$.name.first"}, "", exitError},
+		{[]string{"$.items[(@.missing)]"}, "", exitNoMatch},
+		{[]string{"$.items[?(@.id =~ /a/)]"}, "", exitError},
+		{[]string{"-o", "path", "$[(@.items)]"}, "", exitError},
+	}
+	for _, tcase := range tcases {
+		var stdout, stderr bytes.Buffer
+		code := run(tcase.args, strings.NewReader(input), &stdout, &stderr)
+		if code != tcase.code {
+			t.Errorf("%q: expected exit code %d, got %d: %s", tcase.args, tcase.code, code, stderr.String())
+		}
+		if stdout.String() != tcase.output {
+			t.Errorf("%q: expected output %q, got %q", tcase.args, tcase.output, stdout.String())
+		}
+	}
+}
+
+func Test_runRootEdits(t *testing.T) {
+	tcases := []struct {
+		args   []string
+		input  string
+		output string
+		code   int
+	}{
+		{[]string{"-set", "$=1"}, `{"a": 1}`, "", exitError},
+		{[]string{"-del", "$"}, `{"a": 1}`, "", exitError},
+		{[]string{"-del", "$[0]"}, `[1, 2, 3]`, "[2,3]\n", exitMatch},
+		{[]string{"-del", "$[5]"}, `[1, 2, 3]`, "", exitError},
+		{[]string{"-del", "$[0]"}, `{"a": 1}`, "", exitError},
+	}
+	for _, tcase := range tcases {
+		var stdout, stderr bytes.Buffer
+		code := run(tcase.args, strings.NewReader(tcase.input), &stdout, &stderr)
+		if code != tcase.code {
+			t.Errorf("%q: expected exit code %d, got %d: %s", tcase.args, tcase.code, code, stderr.String())
+		}
+		if stdout.String() != tcase.output {
+			t.Errorf("%q: expected output %q, got %q", tcase.args, tcase.output, stdout.String())
+		}
+	}
+}
+
+func Test_runFormats(t *testing.T) {
+	tcases := []struct {
+		path  string
+		input string
+		json  string
+		nodes string
+	}{
+		{"$.n[*][1]", `{"n": [[1, 2], [3, 4]]}`, "[2,4]\n", "$['n'][0][1]\t2\n$['n'][1][1]\t4\n"},
+		{"$.a.b", `{"a": [{"b": 1}, {"b": 2}]}`, "[1,2]\n", "$['a'][0]['b']\t1\n$['a'][1]['b']\t2\n"},
+		{"$.a.b", `{"a": [{"b": 1}, {"c": 2}]}`, "1\n", "$['a'][0]['b']\t1\n"},
+		{"$.a[0]", `{"a": [[1, 2]]}`, "[1,2]\n", "$['a'][0]\t[1,2]\n"},
+	}
+	for _, tcase := range tcases {
+		for format, output := range map[string]string{"json": tcase.json, "path": tcase.nodes} {
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"-o", format, tcase.path}, strings.NewReader(tcase.input), &stdout, &stderr)
+			if code != exitMatch {
+				t.Errorf("%s %s: expected exit code %d, got %d: %s", format, tcase.path, exitMatch, code, stderr.String())
+			}
+			if stdout.String() != output {
+				t.Errorf("%s %s: expected output %q, got %q", format, tcase.path, output, stdout.String())
+			}
+		}
+	}
+}
+
+func Test_runFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	ioutil.WriteFile(first, []byte(`{"id": 1}`), 0644)
+	ioutil.WriteFile(second, []byte(`{"id": 2} {"id": `), 0644)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"$.id", first, filepath.Join(dir, "missing.json"), second}, nil, &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+	if stdout.String() != "1\n2\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+	if errs := strings.Count(stderr.String(), "\n"); errs != 2 {
+		t.Errorf("expected 2 errors, got %q", stderr.String())
+	}
+}
+
+func Test_splitAssignment(t *testing.T) {
+	tcases := []struct {
+		s, path, value string
+	}{
+		{"$.a=1", "$.a", "1"},
+		{"$.a==1", "$.a", "=1"},
+		{"$.a[?(@.b == 'x=y')].c=d=e", "$.a[?(@.b == 'x=y')].c", "d=e"},
+		{"$['a=b']=\"x\"", "$['a=b']", "\"x\""},
+	}
+	for _, tcase := range tcases {
+		path, value, ok := splitAssignment(tcase.s)
+		if !ok || path != tcase.path || value != tcase.value {
+			t.Errorf("%s: unexpected %q %q", tcase.s, path, value)
+		}
+	}
+	if _, _, ok := splitAssignment("$.a[?(@.b == 1)]"); ok {
+		t.Error("expected no assignment")
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ilyaferilo/jsonpath"
+)
+
+const replHelp = `Enter a path to print normalized paths and values of matched nodes.
+In terminal Tab completes the key before the cursor and Up and Down walk through history.
+  :ast path        show parsed selectors of path
+  :complete path   list completions of the last key of path
+  :history         list history
+  !!               repeat the last line
+  !n               repeat line n of history
+  :help            show this help
+  :quit            exit, like EOF
+`
+
+// repl evaluates paths typed by user against a single document
+type repl struct {
+	doc     interface{}
+	in      lineReader
+	out     io.Writer
+	history []string
+	// histFile keeps history between sessions when it's not nil
+	histFile *os.File
+}
+
+func newREPL(doc interface{}, in io.Reader, out io.Writer) *repl {
+	return &repl{doc: doc, in: &scanReader{in: bufio.NewScanner(in), out: out}, out: out}
+}
+
+// useTerminal reads lines by lineEditor when in is a terminal, restore should be called
+// before exit to switch terminal back from raw mode
+func (r *repl) useTerminal(in *os.File) (restore func(), ok bool) {
+	restore, ok = makeRaw(int(in.Fd()))
+	if !ok {
+		return nil, false
+	}
+	complete := func(line string) ([]string, error) {
+		return completions(r.doc, line)
+	}
+	history := func() []string {
+		return r.history
+	}
+	r.in = newLineEditor(in, r.out, complete, history)
+	return restore, true
+}
+
+// openHistory loads history from file and appends new lines to it
+func (r *repl) openHistory(name string) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := s.Text(); line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return err
+	}
+	r.histFile = f
+	return nil
+}
+
+func (r *repl) close() error {
+	if r.histFile == nil {
+		return nil
+	}
+	return r.histFile.Close()
+}
+
+func (r *repl) run() error {
+	for {
+		line, err := r.in.readLine("> ")
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			expanded, err := r.expand(line)
+			if err != nil {
+				fmt.Fprintf(r.out, "error: %v\n", err)
+				continue
+			}
+			line = expanded
+			fmt.Fprintln(r.out, line)
+		}
+
+		cmd, arg := line, ""
+		if strings.HasPrefix(line, ":") {
+			if i := strings.IndexByte(line, ' '); i > 0 {
+				cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+			}
+		}
+		switch cmd {
+		case ":quit", ":q", ":exit":
+			return nil
+		case ":help", ":h":
+			fmt.Fprint(r.out, replHelp)
+			continue
+		case ":history":
+			for i, h := range r.history {
+				fmt.Fprintf(r.out, "%4d  %s\n", i+1, h)
+			}
+			continue
+		}
+		if strings.HasPrefix(cmd, ":") && cmd != ":ast" && cmd != ":complete" {
+			fmt.Fprintf(r.out, "error: unknown command %s, see :help\n", cmd)
+			continue
+		}
+		if err := r.remember(line); err != nil {
+			return err
+		}
+		switch cmd {
+		case ":ast":
+			r.ast(arg)
+		case ":complete":
+			r.complete(arg)
+		default:
+			r.eval(line)
+		}
+	}
+}
+
+// expand replaces !! and !n by lines of history
+func (r *repl) expand(line string) (string, error) {
+	if len(r.history) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if line == "!!" {
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("no history entry %s", line[1:])
+	}
+	return r.history[n-1], nil
+}
+
+func (r *repl) remember(line string) error {
+	if len(r.history) > 0 && r.history[len(r.history)-1] == line {
+		return nil
+	}
+	r.history = append(r.history, line)
+	if r.histFile == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(r.histFile, line)
+	return err
+}
+
+// eval prints normalized paths and values of nodes matched by path
+func (r *repl) eval(path string) {
+	c, err := jsonpath.Compile(path)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+	matches := 0
+	err = c.Each(r.doc, func(n jsonpath.Node) bool {
+		matches++
+		data, err := json.Marshal(n.Value)
+		if err != nil {
+			data = []byte(fmt.Sprintf("<%v>", err))
+		}
+		fmt.Fprintf(r.out, "%s\t%s\n", n.Path(), data)
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+	if matches > 0 {
+		fmt.Fprintf(r.out, "(%d matched)\n", matches)
+		return
+	}
+	// Lookup explains why nothing matched
+	if _, err := c.Lookup(r.doc); err != nil {
+		fmt.Fprintf(r.out, "no match: %v\n", err)
+		return
+	}
+	fmt.Fprintln(r.out, "no match")
+}
+
+// ast prints parsed selectors of path
+func (r *repl) ast(path string) {
+	c, err := jsonpath.Compile(path)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+	w := tabwriter.NewWriter(r.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\top\tselector")
+	for i, s := range c.Selectors() {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i, s.Op, s)
+	}
+	w.Flush()
+}
+
+func (r *repl) complete(line string) {
+	candidates, err := completions(r.doc, line)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+	if len(candidates) == 0 {
+		fmt.Fprintln(r.out, "no completions")
+		return
+	}
+	for _, c := range candidates {
+		fmt.Fprintln(r.out, c)
+	}
+}
+
+// completions returns paths completing the last key of line by keys
+// of nodes matched by the rest of line
+func completions(doc interface{}, line string) ([]string, error) {
+	if line == "" {
+		line = "$"
+	}
+	base, partial, bracket := splitPartialKey(line)
+	c, err := jsonpath.Compile(base)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	err = c.Each(doc, func(n jsonpath.Node) bool {
+		collectKeys(n.Value, keys)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := []string{}
+	for key := range keys {
+		quoted := jsonpath.QuoteKey(key)
+		if bracket && !strings.HasPrefix(quoted, "['"+partial) || !bracket && !strings.HasPrefix(key, partial) {
+			continue
+		}
+		if bracket || !jsonpath.IsPlainKey(key) {
+			res = append(res, base+quoted)
+		} else {
+			res = append(res, base+"."+key)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// splitPartialKey splits line to the base path and the partial key after it,
+// bracket is set for partial keys like $.store['bo
+func splitPartialKey(line string) (base, partial string, bracket bool) {
+	if i := strings.LastIndex(line, "['"); i >= 0 && !isClosed(line[i+2:]) {
+		return line[:i], line[i+2:], true
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ']', ')':
+			depth++
+		case '[', '(':
+			depth--
+		case '.':
+			if depth == 0 {
+				return line[:i], line[i+1:], false
+			}
+		}
+	}
+	return line, "", false
+}
+
+// isClosed reports whether quoted key contains unescaped closing quote
+func isClosed(key string) bool {
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '\\':
+			i++
+		case '\'':
+			return true
+		}
+	}
+	return false
+}
+
+// collectKeys adds keys of map, for slices keys of its elements are added
+// like key selector does
+func collectKeys(v interface{}, keys map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key := range v {
+			keys[key] = true
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if m, ok := elem.(map[string]interface{}); ok {
+				for key := range m {
+					keys[key] = true
+				}
+			}
+		}
+	}
+}
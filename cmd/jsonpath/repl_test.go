@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ilyaferilo/jsonpath"
+)
+
+const replTestDoc = `{"store": {"book": [{"title": "A", "price": 8}, {"title": "B", "price": 12, "isbn.10": "x"}], "bicycle": {"color": "red"}}}`
+
+func Test_repl(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(replTestDoc), &doc)
+	input := strings.Join([]string{
+		"$.store.book[?(@.price > 10)].title",
+		":ast $.store.book[0:1]",
+		"$.store.missing",
+		"!1",
+		":unknown",
+		":history",
+		":quit",
+		"$.store",
+	}, "\n")
+	var out bytes.Buffer
+	if err := newREPL(doc, strings.NewReader(input), &out).run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `> $['store']['book'][1]['title']	"B"
+(1 matched)
+> #  op     selector
+0  key    ['store']
+1  key    ['book']
+2  range  [0:1]
+> no match: key error: "missing" not found in object
+> $.store.book[?(@.price > 10)].title
+$['store']['book'][1]['title']	"B"
+(1 matched)
+> error: unknown command :unknown, see :help
+>    1  $.store.book[?(@.price > 10)].title
+   2  :ast $.store.book[0:1]
+   3  $.store.missing
+   4  $.store.book[?(@.price > 10)].title
+> `
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func Test_replHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "history")
+	for _, input := range []string{"$.a\n$.b\n", "!1\n"} {
+		r := newREPL(map[string]interface{}{"a": 1}, strings.NewReader(input), &bytes.Buffer{})
+		if err := r.openHistory(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.run(); err != nil {
+			t.Fatal(err)
+		}
+		r.close()
+	}
+	data, _ := ioutil.ReadFile(name)
+	if string(data) != "$.a\n$.b\n$.a\n" {
+		t.Errorf("unexpected history %q", data)
+	}
+}
+
+func Test_completions(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(replTestDoc), &doc)
+	tcases := []struct {
+		line        string
+		completions []string
+	}{
+		{"", []string{"$.store"}},
+		{"$.", []string{"$.store"}},
+		{"$.store.b", []string{"$.store.bicycle", "$.store.book"}},
+		{"$.store.book.", []string{"$.store.book.price", "$.store.book.title", "$.store.book['isbn.10']"}},
+		{"$.store.book[0].t", []string{"$.store.book[0].title"}},
+		{"$.store.book[?(@.price > 10)].i", []string{"$.store.book[?(@.price > 10)]['isbn.10']"}},
+		{"$.store['bi", []string{"$.store['bicycle']"}},
+		{"$.store.x", []string{}},
+	}
+	for _, tcase := range tcases {
+		res, err := completions(doc, tcase.line)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tcase.completions) {
+			t.Errorf("%s: expected %q, got %q", tcase.line, tcase.completions, res)
+		}
+	}
+}
+
+func Test_completionsEscaping(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"": 1, "a]b": 2, "it's": 3, "plain": 4}`), &doc)
+	tcases := []struct {
+		line        string
+		completions []string
+	}{
+		{"$.", []string{"$.plain", `$['']`, `$['a]b']`, `$['it\'s']`}},
+		{"$.i", []string{`$['it\'s']`}},
+		{`$['it\'`, []string{`$['it\'s']`}},
+		{`$['a]`, []string{`$['a]b']`}},
+	}
+	for _, tcase := range tcases {
+		res, err := completions(doc, tcase.line)
+		if err != nil || !reflect.DeepEqual(res, tcase.completions) {
+			t.Errorf("%s: expected %q, got %q, %v", tcase.line, tcase.completions, res, err)
+		}
+		for _, path := range res {
+			if _, err := jsonpath.JsonPathLookup(doc, path); err != nil {
+				t.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+// makeRaw is not supported, lines are read without editing
+func makeRaw(fd int) (restore func(), ok bool) {
+	return nil, false
+}
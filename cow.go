@@ -0,0 +1,270 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// With returns a copy of obj with value set at path, like Set does.
+// obj itself is never modified: only maps and slices along the modified paths
+// are copied, all untouched subtrees are shared between obj and the result.
+func With(obj interface{}, path string, value interface{}) (interface{}, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj = followPtr(obj)
+	if len(c.steps) == 0 {
+		return value, nil
+	}
+
+	w := newCowWriter()
+	setValue := func(parent reflect.Value, key interface{}) (reflect.Value, error) {
+		return w.set(parent, key, value)
+	}
+
+	last := c.steps[len(c.steps)-1]
+	if last.op != KeyOp {
+		locs, err := c.locations(obj)
+		if err != nil {
+			return nil, err
+		}
+		if len(locs) == 0 {
+			return nil, NotExist{key: path}
+		}
+		res := obj
+		for _, loc := range locs {
+			if len(loc) == 0 {
+				return value, nil
+			}
+			if res, err = w.update(res, loc, false, setValue); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	// like Set, the last key is created if it doesn't exist
+	keys := []interface{}{last.key}
+	if subKey, ok := last.args.(string); ok {
+		if last.key == "" {
+			keys = []interface{}{subKey}
+		} else {
+			keys = append(keys, subKey)
+		}
+	}
+	parents := &Compiled{path: c.path, steps: c.steps[:len(c.steps)-1]}
+	var locs []location
+	err = parents.walk(obj, func(loc location, v interface{}) error {
+		v = followPtr(v)
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Map:
+			locs = append(locs, loc)
+		case reflect.Slice:
+			// set key in every element of slice
+			return walkChildren(v, loc, func(child interface{}, childLoc location) error {
+				if reflect.ValueOf(followPtr(child)).Kind() == reflect.Map {
+					locs = append(locs, childLoc)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("incorrect set path %s", path)
+	}
+	res := obj
+	for _, loc := range locs {
+		if res, err = w.update(res, append(loc, keys...), true, setValue); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Without returns a copy of obj with nodes matched by path removed.
+// obj itself is never modified, see With.
+func Without(obj interface{}, path string) (interface{}, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj = followPtr(obj)
+	locs, err := c.locations(obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return nil, NotExist{key: path}
+	}
+
+	w := newCowWriter()
+	res := obj
+	// backward order keeps indexes of not yet removed slice elements valid
+	for i := len(locs) - 1; i >= 0; i-- {
+		if len(locs[i]) == 0 {
+			return nil, fmt.Errorf("could not remove root object")
+		}
+		res, err = w.update(res, locs[i], false, w.remove)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// locations returns locations of all nodes matched by c
+func (c *Compiled) locations(obj interface{}) ([]location, error) {
+	var locs []location
+	err := c.walk(obj, func(loc location, v interface{}) error {
+		locs = append(locs, loc)
+		return nil
+	})
+	return locs, err
+}
+
+// cowWriter copies every container at most once per modification
+type cowWriter struct {
+	owned map[cowKey]bool
+}
+
+type cowKey struct {
+	kind reflect.Kind
+	ptr  uintptr
+	len  int
+}
+
+func newCowWriter() *cowWriter {
+	return &cowWriter{owned: map[cowKey]bool{}}
+}
+
+func (w *cowWriter) key(v reflect.Value) cowKey {
+	if v.Kind() == reflect.Map {
+		// map keeps identity while keys are added
+		return cowKey{v.Kind(), v.Pointer(), 0}
+	}
+	return cowKey{v.Kind(), v.Pointer(), v.Len()}
+}
+
+// own returns copy of the container, containers created by writer are returned as is
+func (w *cowWriter) own(v reflect.Value) reflect.Value {
+	if w.owned[w.key(v)] {
+		return v
+	}
+	var res reflect.Value
+	switch v.Kind() {
+	case reflect.Map:
+		res = reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			res.SetMapIndex(k, v.MapIndex(k))
+		}
+	default:
+		res = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(res, v)
+	}
+	w.owned[w.key(res)] = true
+	return res
+}
+
+// update copies containers along loc and calls fn with the copy of the last parent.
+// Missing map keys are created when create is set.
+func (w *cowWriter) update(obj interface{}, loc location, create bool, fn func(parent reflect.Value, key interface{}) (reflect.Value, error)) (interface{}, error) {
+	obj = followPtr(obj)
+	if obj == nil && create {
+		obj = map[string]interface{}{}
+	}
+	objVal := reflect.ValueOf(obj)
+	if objVal.Kind() != reflect.Map && objVal.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+	parent := w.own(objVal)
+	if len(loc) == 1 {
+		res, err := fn(parent, loc[0])
+		if err != nil {
+			return nil, err
+		}
+		return res.Interface(), nil
+	}
+
+	var child interface{}
+	childVal, err := w.child(parent, loc[0])
+	if err != nil {
+		return nil, err
+	}
+	if childVal.IsValid() {
+		child = childVal.Interface()
+	} else if !create {
+		return nil, NotExist{key: fmt.Sprint(loc[0])}
+	}
+	newChild, err := w.update(child, loc[1:], create, fn)
+	if err != nil {
+		return nil, err
+	}
+	parent, err = w.set(parent, loc[0], newChild)
+	if err != nil {
+		return nil, err
+	}
+	return parent.Interface(), nil
+}
+
+func (w *cowWriter) child(parent reflect.Value, key interface{}) (reflect.Value, error) {
+	switch parent.Kind() {
+	case reflect.Map:
+		keyVal, err := convertValue(parent.Type().Key(), key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return parent.MapIndex(keyVal), nil
+	default:
+		idx, ok := key.(int)
+		if !ok || idx < 0 || idx >= parent.Len() {
+			return reflect.Value{}, fmt.Errorf("index out of range: len: %v, idx: %v", parent.Len(), key)
+		}
+		return parent.Index(idx), nil
+	}
+}
+
+func (w *cowWriter) set(parent reflect.Value, key interface{}, value interface{}) (reflect.Value, error) {
+	newVal, err := convertValue(parent.Type().Elem(), value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	switch parent.Kind() {
+	case reflect.Map:
+		keyVal, err := convertValue(parent.Type().Key(), key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		parent.SetMapIndex(keyVal, newVal)
+	default:
+		elem, err := w.child(parent, key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		elem.Set(newVal)
+	}
+	return parent, nil
+}
+
+func (w *cowWriter) remove(parent reflect.Value, key interface{}) (reflect.Value, error) {
+	if _, err := w.child(parent, key); err != nil {
+		return reflect.Value{}, err
+	}
+	switch parent.Kind() {
+	case reflect.Map:
+		keyVal, _ := convertValue(parent.Type().Key(), key)
+		parent.SetMapIndex(keyVal, reflect.Value{})
+		return parent, nil
+	default:
+		idx := key.(int)
+		res := reflect.MakeSlice(parent.Type(), 0, parent.Len()-1)
+		res = reflect.AppendSlice(res, parent.Slice(0, idx))
+		res = reflect.AppendSlice(res, parent.Slice(idx+1, parent.Len()))
+		w.owned[w.key(res)] = true
+		return res, nil
+	}
+}
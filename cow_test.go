@@ -0,0 +1,111 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func cowTestData() interface{} {
+	var data interface{}
+	json.Unmarshal([]byte(`{
+		"user": {"name": "seth", "tags": ["a", "b", "c"]},
+		"books": [{"price": 5, "title": "x"}, {"price": 15, "title": "y"}, {"price": 25, "title": "z"}],
+		"other": {"deep": {"value": 1}}
+	}`), &data)
+	return data
+}
+
+func Test_With(t *testing.T) {
+	data := cowTestData()
+	orig := deepCopy(data)
+
+	res, err := With(data, "$.user.name", "james")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.user.name"); v != "james" {
+		t.Errorf("value was not set: %v", v)
+	}
+	if !reflect.DeepEqual(data, orig) {
+		t.Errorf("original was modified: %v", data)
+	}
+	// untouched subtrees are shared
+	if reflect.ValueOf(res.(map[string]interface{})["other"]).Pointer() != reflect.ValueOf(data.(map[string]interface{})["other"]).Pointer() {
+		t.Error("untouched subtree was copied")
+	}
+	if reflect.ValueOf(res.(map[string]interface{})["user"]).Pointer() == reflect.ValueOf(data.(map[string]interface{})["user"]).Pointer() {
+		t.Error("modified subtree was not copied")
+	}
+
+	res, err = With(data, "$.books[?(@.price > 10)].title", "expensive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.books[:].title"); !reflect.DeepEqual(v, []interface{}{"x", "expensive", "expensive"}) {
+		t.Errorf("unexpected titles: %v", v)
+	}
+
+	res, err = With(data, "$.user.tags[1]", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.user.tags"); !reflect.DeepEqual(v, []interface{}{"a", "B", "c"}) {
+		t.Errorf("unexpected tags: %v", v)
+	}
+
+	res, err = With(data, "$.other.radio['a']", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.other.radio.a"); v != 3 {
+		t.Errorf("unexpected value: %v", v)
+	}
+
+	if !reflect.DeepEqual(data, orig) {
+		t.Errorf("original was modified: %v", data)
+	}
+
+	if _, err := With(data, "$.not.correct", 1); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := With(data, "$.books[7]", 1); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func Test_Without(t *testing.T) {
+	data := cowTestData()
+	orig := deepCopy(data)
+
+	res, err := Without(data, "$.books[?(@.price < 20)]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.books[:].title"); !reflect.DeepEqual(v, []interface{}{"z"}) {
+		t.Errorf("unexpected books: %v", v)
+	}
+
+	res, err = Without(res, "$.user.tags[0,2]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.user.tags"); !reflect.DeepEqual(v, []interface{}{"b"}) {
+		t.Errorf("unexpected tags: %v", v)
+	}
+
+	res, err = Without(res, "$.other.deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := JsonPathLookup(res, "$.other"); !reflect.DeepEqual(v, map[string]interface{}{}) {
+		t.Errorf("unexpected other: %v", v)
+	}
+
+	if !reflect.DeepEqual(data, orig) {
+		t.Errorf("original was modified: %v", data)
+	}
+	if _, err := Without(data, "$.missing"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,439 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Types of changes reported by Diff
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// Change is a difference between two documents
+type Change struct {
+	// Type is one of DiffAdded, DiffRemoved or DiffChanged
+	Type string
+	// Path is normalized path of the value, in the new document for added and changed values
+	// and in the old document for removed values
+	Path string
+	// Old is the value in the old document, nil for added values
+	Old interface{}
+	// New is the value in the new document, nil for removed values
+	New interface{}
+}
+
+// DiffOptions configures DiffWith
+type DiffOptions struct {
+	// ArrayKey is a path relative to array element, like @.id, which identifies elements
+	// of arrays, so elements are compared with elements of the same key regardless of
+	// their indexes. Arrays are compared by index when it's empty or when any element
+	// has no key or keys are not unique. Removed elements are reported before the others.
+	ArrayKey string
+}
+
+// Diff returns changes which turn document a to document b.
+// Maps are compared by keys and arrays by indexes, numbers are equal regardless of their Go types.
+// Changes are ordered by keys of maps and indexes of arrays.
+//
+//	Diff({"a": 1, "b": [1, 2]}, {"a": 2, "b": [1]})
+//	// changed $['a'] 1 -> 2, removed $['b'][1] 2
+func Diff(a, b interface{}) []Change {
+	d := &differ{}
+	d.diff(a, b, nil, nil)
+	return d.changes
+}
+
+// DiffWith is Diff configured by opts
+func DiffWith(a, b interface{}, opts DiffOptions) ([]Change, error) {
+	d := &differ{}
+	if opts.ArrayKey != "" {
+		c, err := DefaultCache.Compile(opts.ArrayKey)
+		if err != nil {
+			return nil, err
+		}
+		d.key = c
+	}
+	d.diff(a, b, nil, nil)
+	return d.changes, nil
+}
+
+type differ struct {
+	key     *Compiled
+	changes []Change
+}
+
+func (d *differ) add(typ string, loc location, oldValue, newValue interface{}) {
+	d.changes = append(d.changes, Change{Type: typ, Path: loc.String(), Old: oldValue, New: newValue})
+}
+
+// diff compares a at locA of the old document with b at locB of the new one
+func (d *differ) diff(a, b interface{}, locA, locB location) {
+	a, b = followPtr(a), followPtr(b)
+	if am, ok := asMap(a); ok {
+		if bm, ok := asMap(b); ok {
+			d.diffMaps(am, bm, locA, locB)
+			return
+		}
+	}
+	aLen, aIsSlice := sliceLen(a)
+	bLen, bIsSlice := sliceLen(b)
+	if aIsSlice && bIsSlice {
+		if d.key == nil || !d.diffByKey(a, b, aLen, bLen, locA, locB) {
+			d.diffByIndex(a, b, aLen, bLen, locA, locB)
+		}
+		return
+	}
+	if !valuesEqual(a, b) {
+		d.add(DiffChanged, locB, a, b)
+	}
+}
+
+func (d *differ) diffMaps(a, b map[string]interface{}, locA, locB location) {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		av, inA := a[key]
+		bv, inB := b[key]
+		switch {
+		case !inB:
+			d.add(DiffRemoved, locA.with(key), av, nil)
+		case !inA:
+			d.add(DiffAdded, locB.with(key), nil, bv)
+		default:
+			d.diff(av, bv, locA.with(key), locB.with(key))
+		}
+	}
+}
+
+func (d *differ) diffByIndex(a, b interface{}, aLen, bLen int, locA, locB location) {
+	for i := 0; i < aLen || i < bLen; i++ {
+		switch {
+		case i >= bLen:
+			d.add(DiffRemoved, locA.with(i), sliceElem(a, i), nil)
+		case i >= aLen:
+			d.add(DiffAdded, locB.with(i), nil, sliceElem(b, i))
+		default:
+			d.diff(sliceElem(a, i), sliceElem(b, i), locA.with(i), locB.with(i))
+		}
+	}
+}
+
+// diffByKey compares elements with the same keys, returns false when elements
+// could not be identified by keys
+func (d *differ) diffByKey(a, b interface{}, aLen, bLen int, locA, locB location) bool {
+	aKeys, ok := d.elemKeys(a, aLen)
+	if !ok {
+		return false
+	}
+	bKeys, ok := d.elemKeys(b, bLen)
+	if !ok {
+		return false
+	}
+	aIndex := make(map[string]int, aLen)
+	for i, key := range aKeys {
+		aIndex[key] = i
+	}
+	bIndex := make(map[string]int, bLen)
+	for j, key := range bKeys {
+		bIndex[key] = j
+	}
+	for i, key := range aKeys {
+		if _, ok := bIndex[key]; !ok {
+			d.add(DiffRemoved, locA.with(i), sliceElem(a, i), nil)
+		}
+	}
+	for j, key := range bKeys {
+		i, ok := aIndex[key]
+		if !ok {
+			d.add(DiffAdded, locB.with(j), nil, sliceElem(b, j))
+			continue
+		}
+		d.diff(sliceElem(a, i), sliceElem(b, j), locA.with(i), locB.with(j))
+	}
+	return true
+}
+
+// elemKeys returns identity keys of slice elements, ok is false when
+// any element has no key or keys are not unique
+func (d *differ) elemKeys(arr interface{}, length int) ([]string, bool) {
+	keys := make([]string, length)
+	seen := make(map[string]bool, length)
+	for i := range keys {
+		v, err := d.key.Lookup(sliceElem(arr, i))
+		if err != nil {
+			return nil, false
+		}
+		key := identityKey(v)
+		if seen[key] {
+			return nil, false
+		}
+		seen[key] = true
+		keys[i] = key
+	}
+	return keys, true
+}
+
+// identityKey returns string which is equal for equal values, numbers are equal regardless of their types
+func identityKey(v interface{}) string {
+	v = followPtr(v)
+	if isNumeric(v) {
+		if r, ok := toRat(v); ok {
+			return "n" + r.RatString()
+		}
+	}
+	if s, ok := v.(string); ok {
+		return "s" + s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("v%#v", v)
+	}
+	return "j" + string(data)
+}
+
+// asMap returns map with string keys as map[string]interface{}
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	res := make(map[string]interface{}, rv.Len())
+	for _, key := range rv.MapKeys() {
+		res[key.String()] = rv.MapIndex(key).Interface()
+	}
+	return res, true
+}
+
+// CreatePatch returns JSON Patch which turns document a to document b.
+// Values which differ are replaced, keys of maps are added and removed, renamed keys
+// with equal values are moved. Array elements equal to elements of the other array are
+// kept and reordered by the minimal number of moves, the others are compared by order or
+// added and removed. Values of operations are shared with b.
+//
+//	CreatePatch({"a": [1, 2, 3]}, {"a": [3, 1, 2]})
+//	// [{"op": "move", "from": "/a/2", "path": "/a/0"}]
+func CreatePatch(a, b interface{}) Patch {
+	p := &patcher{ops: Patch{}}
+	p.diff(a, b, nil)
+	return p.ops
+}
+
+type patcher struct {
+	ops Patch
+}
+
+func (p *patcher) add(op string, loc location, value interface{}) {
+	p.ops = append(p.ops, Operation{Op: op, Path: loc.pointer(), Value: value})
+}
+
+func (p *patcher) move(from, to location) {
+	p.ops = append(p.ops, Operation{Op: PatchMove, From: from.pointer(), Path: to.pointer()})
+}
+
+// diff adds operations turning a to b at loc, which is the same in both documents
+func (p *patcher) diff(a, b interface{}, loc location) {
+	a, b = followPtr(a), followPtr(b)
+	if am, ok := asMap(a); ok {
+		if bm, ok := asMap(b); ok {
+			p.diffMaps(am, bm, loc)
+			return
+		}
+	}
+	aLen, aIsSlice := sliceLen(a)
+	bLen, bIsSlice := sliceLen(b)
+	if aIsSlice && bIsSlice {
+		p.diffSlices(a, b, aLen, bLen, loc)
+		return
+	}
+	if !valuesEqual(a, b) {
+		p.add(PatchReplace, loc, b)
+	}
+}
+
+func (p *patcher) diffMaps(a, b map[string]interface{}, loc location) {
+	var removed, added, common []string
+	for key := range a {
+		if _, ok := b[key]; ok {
+			common = append(common, key)
+		} else {
+			removed = append(removed, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	sort.Strings(common)
+
+	// renamed keys
+	moved := map[string]bool{}
+	for _, from := range removed {
+		for _, to := range added {
+			if !moved[to] && valuesEqual(a[from], b[to]) {
+				p.move(loc.with(from), loc.with(to))
+				moved[from], moved[to] = true, true
+				break
+			}
+		}
+	}
+	for _, key := range removed {
+		if !moved[key] {
+			p.add(PatchRemove, loc.with(key), nil)
+		}
+	}
+	for _, key := range added {
+		if !moved[key] {
+			p.add(PatchAdd, loc.with(key), b[key])
+		}
+	}
+	for _, key := range common {
+		p.diff(a[key], b[key], loc.with(key))
+	}
+}
+
+// diffSlices keeps elements of a equal to elements of b and pairs the rest by order,
+// so they are compared recursively. Unpaired elements are removed or added, kept elements
+// out of the longest increasing sequence of their target indexes are moved.
+func (p *patcher) diffSlices(a, b interface{}, aLen, bLen int, loc location) {
+	// target[i] is the index in b of a[i] or -1 when a[i] is removed
+	target := make([]int, aLen)
+	// source[j] is the index in a of b[j] or -1 when b[j] is added
+	source := make([]int, bLen)
+	byValue := map[string][]int{}
+	for i := 0; i < aLen; i++ {
+		target[i] = -1
+		key := identityKey(sliceElem(a, i))
+		byValue[key] = append(byValue[key], i)
+	}
+	for j := 0; j < bLen; j++ {
+		source[j] = -1
+		key := identityKey(sliceElem(b, j))
+		if same := byValue[key]; len(same) > 0 {
+			source[j], target[same[0]] = same[0], j
+			byValue[key] = same[1:]
+		}
+	}
+	var unmatchedA, unmatchedB []int
+	for i, j := range target {
+		if j < 0 {
+			unmatchedA = append(unmatchedA, i)
+		}
+	}
+	for j, i := range source {
+		if i < 0 {
+			unmatchedB = append(unmatchedB, j)
+		}
+	}
+	// changed elements
+	for k := 0; k < len(unmatchedA) && k < len(unmatchedB); k++ {
+		i, j := unmatchedA[k], unmatchedB[k]
+		target[i], source[j] = j, i
+	}
+
+	// cur contains target indexes of elements in the current state of slice
+	cur := make([]int, 0, aLen)
+	for i := aLen - 1; i >= 0; i-- {
+		if target[i] < 0 {
+			p.add(PatchRemove, loc.with(i), nil)
+		}
+	}
+	for _, j := range target {
+		if j >= 0 {
+			cur = append(cur, j)
+		}
+	}
+
+	keep := increasingSubsequence(cur)
+	// elements are placed in order of targets after the element with the previous target
+	for j := 0; j < bLen; j++ {
+		to := 0
+		if j > 0 {
+			to = indexOf(cur, j-1) + 1
+		}
+		if source[j] < 0 {
+			p.add(PatchAdd, loc.with(to), sliceElem(b, j))
+			cur = insertAt(cur, to, j)
+			continue
+		}
+		if keep[j] {
+			continue
+		}
+		from := indexOf(cur, j)
+		if from < to {
+			to--
+		}
+		if from != to {
+			p.move(loc.with(from), loc.with(to))
+		}
+		cur = insertAt(append(cur[:from], cur[from+1:]...), to, j)
+	}
+
+	for j := 0; j < bLen; j++ {
+		if i := source[j]; i >= 0 {
+			p.diff(sliceElem(a, i), sliceElem(b, j), loc.with(j))
+		}
+	}
+}
+
+// increasingSubsequence returns values of the longest increasing subsequence of seq
+func increasingSubsequence(seq []int) map[int]bool {
+	// tails[k] is the index in seq of the last element of the subsequence of length k+1
+	var tails []int
+	prev := make([]int, len(seq))
+	for i, v := range seq {
+		k := sort.Search(len(tails), func(k int) bool { return seq[tails[k]] >= v })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	res := map[int]bool{}
+	if len(tails) == 0 {
+		return res
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		res[seq[i]] = true
+	}
+	return res
+}
+
+func indexOf(seq []int, v int) int {
+	for i, x := range seq {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func insertAt(seq []int, i, v int) []int {
+	seq = append(seq, 0)
+	copy(seq[i+1:], seq[i:])
+	seq[i] = v
+	return seq
+}
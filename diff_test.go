@@ -0,0 +1,144 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffDocs(a, b string) (interface{}, interface{}) {
+	var docA, docB interface{}
+	json.Unmarshal([]byte(a), &docA)
+	json.Unmarshal([]byte(b), &docB)
+	return docA, docB
+}
+
+func formatChanges(changes []Change) string {
+	res := make([]string, len(changes))
+	for i, c := range changes {
+		before, _ := json.Marshal(c.Old)
+		after, _ := json.Marshal(c.New)
+		res[i] = fmt.Sprintf("%s %s %s %s", c.Type, c.Path, before, after)
+	}
+	return strings.Join(res, "\n")
+}
+
+func Test_Diff(t *testing.T) {
+	tcases := []struct {
+		a, b    string
+		changes string
+	}{
+		{`{"a": 1}`, `{"a": 1}`, ""},
+		{`1`, `2`, "changed $ 1 2"},
+		{`{"a": 1, "b": {"c": true}, "x": null}`, `{"a": 2, "b": {"d": "y"}, "x": null}`,
+			"changed $['a'] 1 2\nremoved $['b']['c'] true null\nadded $['b']['d'] null \"y\""},
+		{`{"a": [1, 2, 3]}`, `{"a": [1, 5]}`, "changed $['a'][1] 2 5\nremoved $['a'][2] 3 null"},
+		{`{"a": [1]}`, `{"a": [1, {"b": 2}]}`, "added $['a'][1] null {\"b\":2}"},
+		{`{"a": {"b": 1}}`, `{"a": [1]}`, "changed $['a'] {\"b\":1} [1]"},
+		{`{"a": "1"}`, `{"a": 1}`, "changed $['a'] \"1\" 1"},
+	}
+	for _, tcase := range tcases {
+		a, b := diffDocs(tcase.a, tcase.b)
+		if changes := formatChanges(Diff(a, b)); changes != tcase.changes {
+			t.Errorf("%s -> %s: expected\n%s\ngot\n%s", tcase.a, tcase.b, tcase.changes, changes)
+		}
+	}
+
+	// numbers are compared by value
+	a := map[string]interface{}{"n": 1, "l": []int{1, 2}}
+	b := map[string]interface{}{"n": json.Number("1.0"), "l": []interface{}{1.0, 2.0}}
+	if changes := Diff(a, b); len(changes) != 0 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func Test_DiffWithArrayKey(t *testing.T) {
+	a, b := diffDocs(
+		`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"}], "tags": ["x", "y"]}`,
+		`{"users": [{"id": 3, "name": "c"}, {"id": 1, "name": "A"}, {"id": 4, "name": "d"}], "tags": ["y"]}`,
+	)
+	changes, err := DiffWith(a, b, DiffOptions{ArrayKey: "@.id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"changed $['tags'][0] \"x\" \"y\"",
+		"removed $['tags'][1] \"y\" null",
+		"removed $['users'][1] {\"id\":2,\"name\":\"b\"} null",
+		"changed $['users'][1]['name'] \"a\" \"A\"",
+		"added $['users'][2] null {\"id\":4,\"name\":\"d\"}",
+	}, "\n")
+	if res := formatChanges(changes); res != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, res)
+	}
+
+	// elements without unique keys are compared by index
+	a, b = diffDocs(`[{"id": 1}, {"id": 1}]`, `[{"id": 1}]`)
+	changes, _ = DiffWith(a, b, DiffOptions{ArrayKey: "@.id"})
+	if !reflect.DeepEqual(changes, Diff(a, b)) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	if _, err := DiffWith(a, b, DiffOptions{ArrayKey: "@.id["}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func Test_CreatePatch(t *testing.T) {
+	tcases := []struct {
+		a, b  string
+		patch string
+	}{
+		{`{"a": 1}`, `{"a": 1}`, `[]`},
+		{`1`, `"x"`, `[{"op":"replace","path":"","value":"x"}]`},
+		{`{"a": 1, "b": 2, "c": {"d": 1}}`, `{"a": 1, "x": 3, "c": {"d": 2}}`,
+			`[{"op":"remove","path":"/b"},{"op":"add","path":"/x","value":3},{"op":"replace","path":"/c/d","value":2}]`},
+		{`{"a": {"big": [1, 2]}, "k": 1}`, `{"b": {"big": [1, 2]}, "k": 1}`, `[{"from":"/a","op":"move","path":"/b"}]`},
+		{`{"a~/b": 1}`, `{"a~/b": 2}`, `[{"op":"replace","path":"/a~0~1b","value":2}]`},
+		{`[1, 2, 3]`, `[3, 1, 2]`, `[{"from":"/2","op":"move","path":"/0"}]`},
+		{`[1, 2, 3]`, `[2, 3, 1]`, `[{"from":"/0","op":"move","path":"/2"}]`},
+		{`[1, 2, 3, 4]`, `[1, 3, 4]`, `[{"op":"remove","path":"/1"}]`},
+		{`[1, 3]`, `[1, 2, 3, 4]`, `[{"op":"add","path":"/1","value":2},{"op":"add","path":"/3","value":4}]`},
+		{`[{"id": 1, "v": "a"}, {"id": 2}]`, `[{"id": 2}, {"id": 1, "v": "b"}]`,
+			`[{"from":"/0","op":"move","path":"/1"},{"op":"replace","path":"/1/v","value":"b"}]`},
+		{`{"a": [1, 2]}`, `{"a": {"0": 1}}`, `[{"op":"replace","path":"/a","value":{"0":1}}]`},
+	}
+	for _, tcase := range tcases {
+		a, b := diffDocs(tcase.a, tcase.b)
+		patch := CreatePatch(a, b)
+		data, _ := json.Marshal(patch)
+		if string(data) != tcase.patch {
+			t.Errorf("%s -> %s: expected %s, got %s", tcase.a, tcase.b, tcase.patch, data)
+		}
+		res, err := ApplyPatch(a, patch)
+		if err != nil || !reflect.DeepEqual(res, b) {
+			t.Errorf("%s -> %s: patch produced %v, %v", tcase.a, tcase.b, res, err)
+		}
+	}
+}
+
+func Test_CreatePatchApplies(t *testing.T) {
+	docs := []string{
+		`[]`,
+		`[1, 2, 3, 4, 5, 6]`,
+		`[6, 5, 4, 3, 2, 1]`,
+		`[2, 2, 1, 1, 7]`,
+		`[{"id": 1}, 2, [3], "4", null, {"id": 1}]`,
+		`[[1, 2], {"a": [3, 1]}, 5, 2, 6, 1]`,
+		`{"a": [3, 1, 2], "b": {"c": [1, {"d": 2}]}}`,
+		`{"a": [2, 1], "c": {"c": [{"d": 3}, 1, 4]}}`,
+	}
+	for _, from := range docs {
+		for _, to := range docs {
+			a, b := diffDocs(from, to)
+			patch := CreatePatch(a, b)
+			res, err := ApplyPatch(a, patch)
+			if err != nil || !reflect.DeepEqual(res, b) {
+				data, _ := json.Marshal(patch)
+				t.Errorf("%s -> %s: patch %s produced %v, %v", from, to, data, res, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package jsonpath
+
+// Node is a value matched by path.
+// Node is valid only during the call of Each callback, Path should be called there.
+type Node struct {
+	Value interface{}
+	path  []pathElem
+}
+
+// Path returns normalized path of the node, like $['store']['book'][0]
+func (n Node) Path() string {
+	w := walker{path: n.path}
+	return w.location().String()
+}
+
+// Each calls fn for every node matched by c in document order, iteration stops when fn returns false.
+// Unlike Lookup, intermediate results are not collected: matches are visited one by one
+// as they are found and nothing is allocated for documents decoded by encoding/json,
+// except for filters and expressions evaluation.
+// Missing keys and out of range indexes are skipped instead of being reported.
+func (c *Compiled) Each(obj interface{}, fn func(Node) bool) error {
+	obj = followPtr(obj)
+	steps := c.selectors()
+	w := &walker{root: obj, path: make([]pathElem, 0, len(steps))}
+	w.fn = func(w *walker, v interface{}) error {
+		if !fn(Node{Value: v, path: w.path}) {
+			return errStopWalk
+		}
+		return nil
+	}
+	err := w.steps(obj, steps)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+//go:build go1.23
+
+package jsonpath
+
+import "iter"
+
+// All returns iterator over normalized paths and values of nodes matched by c,
+// nodes are found lazily while iterating like in Each.
+// Evaluation errors stop iteration, use Each to get them.
+//
+//	for path, v := range c.All(obj) {
+//		fmt.Println(path, v)
+//	}
+func (c *Compiled) All(obj any) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		c.Each(obj, func(n Node) bool {
+			return yield(n.Path(), n.Value)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_All(t *testing.T) {
+	c := MustCompile("$.store.book[*].author")
+
+	var paths []string
+	var authors []interface{}
+	for path, v := range c.All(json_data) {
+		paths = append(paths, path)
+		authors = append(authors, v)
+	}
+	expected, _ := c.Lookup(json_data)
+	if !reflect.DeepEqual(authors, expected) {
+		t.Errorf("expected %v, got %v", expected, authors)
+	}
+	if paths[1] != "$['store']['book'][1]['author']" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+
+	for path := range c.All(json_data) {
+		if path != "$['store']['book'][0]['author']" {
+			t.Errorf("unexpected first path: %s", path)
+		}
+		break
+	}
+}
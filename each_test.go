@@ -0,0 +1,75 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Each(t *testing.T) {
+	c := MustCompile("$.store.book[?(@.price < 20)].title")
+
+	var paths []string
+	var titles []interface{}
+	err := c.Each(json_data, func(n Node) bool {
+		paths = append(paths, n.Path())
+		titles = append(titles, n.Value)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := c.Lookup(json_data)
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v, got %v", expected, titles)
+	}
+	if paths[0] != "$['store']['book'][0]['title']" || len(paths) != len(titles) {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+
+	// early termination
+	count := 0
+	err = MustCompile("$.store.book[*].author").Each(json_data, func(n Node) bool {
+		count++
+		return false
+	})
+	if err != nil || count != 1 {
+		t.Errorf("iteration was not stopped: %d, %v", count, err)
+	}
+
+	err = MustCompile("$.store.book[?(@.price =~ /1/)]").Each(json_data, func(n Node) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expected evaluation error")
+	}
+}
+
+func eachBenchData() interface{} {
+	items := make([]interface{}, 1000)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": float64(i), "tags": []interface{}{"a", "b"}}
+	}
+	return map[string]interface{}{"items": items}
+}
+
+func BenchmarkEach(b *testing.B) {
+	data := eachBenchData()
+	c := MustCompile("$.items[*].tags[0]")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Each(data, func(n Node) bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkEachLookup(b *testing.B) {
+	data := eachBenchData()
+	c := MustCompile("$.items[*].tags[0]")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Lookup(data)
+	}
+}
@@ -0,0 +1,358 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrRequired is reported for fields with required option when path matched nothing
+var ErrRequired = errors.New("required value not found")
+
+// FieldError is an error of a single field reported by Extract
+type FieldError struct {
+	// Field is the name of field, like Customer.Name or Items[1].Sku for nested structs
+	Field string
+	Path  string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (%s): %v", e.Field, e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractError is returned by Extract with errors of all fields which could not be extracted
+type ExtractError struct {
+	Errors []*FieldError
+}
+
+func (e *ExtractError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("could not extract %d fields: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Is reports whether error of any field is target, so errors.Is(err, ErrRequired)
+// reports missing required fields
+func (e *ExtractError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns errors of fields for errors.As of Go 1.20 and later
+func (e *ExtractError) Unwrap() []error {
+	res := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		res[i] = err
+	}
+	return res
+}
+
+// Unmarshal decodes JSON data and extracts it to struct pointed by v like Extract does
+func Unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return err
+	}
+	return Extract(obj, v)
+}
+
+// Extract sets fields of struct pointed by v to values matched by paths of their jsonpath tags.
+// Tag contains path and options after comma:
+//
+//	type Order struct {
+//		ID     string   `jsonpath:"$.order.id,required"`
+//		Status string   `jsonpath:"$.order.state.name,default=new"`
+//		SKUs   []string `jsonpath:"$.order.items[*].sku"`
+//		Total  float64  `jsonpath:"$.order.total"`
+//	}
+//
+// Values are converted to field types without loss of precision, the other types are
+// decoded by encoding/json. Fields of struct types with jsonpath tags, pointers and slices
+// of them are extracted recursively, their paths are relative to the matched node.
+// Default is parsed as JSON, except for string fields. Fields without results
+// keep their values unless default is set. Errors of all fields are returned in ExtractError.
+// Tags are parsed once per type.
+func Extract(obj interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("extract target should be a non-nil pointer to struct, got %T", v)
+	}
+	x := &extractor{}
+	if err := x.extract(followPtr(obj), rv.Elem(), ""); err != nil {
+		return err
+	}
+	if len(x.errs) > 0 {
+		return &ExtractError{Errors: x.errs}
+	}
+	return nil
+}
+
+// fieldPlan is a parsed jsonpath tag of struct field
+type fieldPlan struct {
+	index    []int
+	name     string
+	path     *Compiled
+	required bool
+	// def is assigned like matched values, so slices and maps are not shared
+	def    interface{}
+	hasDef bool
+	// omitEmpty is used by Marshal only
+	omitEmpty bool
+}
+
+type structPlan struct {
+	fields []fieldPlan
+	err    error
+}
+
+var structPlans = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structPlan
+}{m: map[reflect.Type]*structPlan{}}
+
+// planOf returns parsed tags of struct type t
+func planOf(t reflect.Type) *structPlan {
+	structPlans.RLock()
+	plan, ok := structPlans.m[t]
+	structPlans.RUnlock()
+	if ok {
+		return plan
+	}
+	plan = &structPlan{}
+	plan.fields, plan.err = parseTags(t, nil)
+	structPlans.Lock()
+	structPlans.m[t] = plan
+	structPlans.Unlock()
+	return plan
+}
+
+// parseTags parses tags of t fields, fields of embedded structs without tags are included
+func parseTags(t reflect.Type, index []int) ([]fieldPlan, error) {
+	var res []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("jsonpath")
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if !ok && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields, err := parseTags(f.Type, fieldIndex)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, fields...)
+			continue
+		}
+		if !ok || tag == "-" {
+			continue
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("invalid jsonpath tag of %v.%s: field is not exported", t, f.Name)
+		}
+		plan, err := parseTag(tag, f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonpath tag of %v.%s: %v", t, f.Name, err)
+		}
+		plan.index, plan.name = fieldIndex, f.Name
+		res = append(res, plan)
+	}
+	return res, nil
+}
+
+// parseTag parses path and options, default is the last option because it may contain commas
+func parseTag(tag string, t reflect.Type) (fieldPlan, error) {
+	var plan fieldPlan
+	path, opts := splitTag(tag)
+	c, err := Compile(path)
+	if err != nil {
+		return plan, err
+	}
+	plan.path = c
+	for opts != "" {
+		var opt string
+		if strings.HasPrefix(opts, "default=") {
+			opt, opts = opts, ""
+		} else if i := strings.IndexByte(opts, ','); i >= 0 {
+			opt, opts = opts[:i], opts[i+1:]
+		} else {
+			opt, opts = opts, ""
+		}
+		switch {
+		case opt == "required":
+			plan.required = true
+		case opt == "omitempty":
+			plan.omitEmpty = true
+		case strings.HasPrefix(opt, "default="):
+			def, err := parseDefault(strings.TrimPrefix(opt, "default="), t)
+			if err != nil {
+				return plan, err
+			}
+			plan.def, plan.hasDef = def, true
+		default:
+			return plan, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return plan, nil
+}
+
+// splitTag splits tag at the first comma outside of brackets and quotes
+func splitTag(tag string) (path, opts string) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			return tag[:i], tag[i+1:]
+		}
+	}
+	return tag, ""
+}
+
+// parseDefault parses default as JSON for types other than string
+// and checks it could be assigned to type t
+func parseDefault(s string, t reflect.Type) (interface{}, error) {
+	if t.Kind() == reflect.String {
+		return s, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid default %q: %v", s, err)
+	}
+	if err := assignValue(reflect.New(t).Elem(), v); err != nil {
+		return nil, fmt.Errorf("invalid default %q: %v", s, err)
+	}
+	return v, nil
+}
+
+// extractor collects errors of all fields
+type extractor struct {
+	errs []*FieldError
+}
+
+func (x *extractor) extract(obj interface{}, dst reflect.Value, prefix string) error {
+	plan := planOf(dst.Type())
+	if plan.err != nil {
+		return plan.err
+	}
+	for _, f := range plan.fields {
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + f.name
+		}
+		res, err := f.path.Lookup(obj)
+		if err != nil && !IsNotFound(err) {
+			x.errs = append(x.errs, &FieldError{Field: name, Path: f.path.path, Err: err})
+			continue
+		}
+		if err != nil || res == nil || isEmptyList(res) {
+			switch {
+			case f.hasDef:
+				assignValue(dst.FieldByIndex(f.index), f.def)
+			case f.required:
+				x.errs = append(x.errs, &FieldError{Field: name, Path: f.path.path, Err: ErrRequired})
+			}
+			continue
+		}
+		if err := x.assign(dst.FieldByIndex(f.index), res, name); err != nil {
+			x.errs = append(x.errs, &FieldError{Field: name, Path: f.path.path, Err: err})
+		}
+	}
+	return nil
+}
+
+// assign sets dst to value, structs with jsonpath tags are extracted recursively
+func (x *extractor) assign(dst reflect.Value, value interface{}, name string) error {
+	t := dst.Type()
+	switch {
+	case hasTags(t):
+		return x.extract(value, dst, name)
+	case t.Kind() == reflect.Ptr && hasTags(t.Elem()):
+		elem := reflect.New(t.Elem())
+		if err := x.extract(value, elem.Elem(), name); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case t.Kind() == reflect.Slice && (hasTags(t.Elem()) || t.Elem().Kind() == reflect.Ptr && hasTags(t.Elem().Elem())):
+		length, ok := sliceLen(value)
+		if !ok {
+			return &TypeError{Value: value, Type: t}
+		}
+		res := reflect.MakeSlice(t, length, length)
+		for i := 0; i < length; i++ {
+			if err := x.assign(res.Index(i), sliceElem(value, i), fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(res)
+		return nil
+	}
+	return assignValue(dst, value)
+}
+
+// hasTags reports whether t is a struct with jsonpath tags
+func hasTags(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	plan := planOf(t)
+	return plan.err != nil || len(plan.fields) > 0
+}
+
+// assignValue converts value like Set does, the other types are decoded by encoding/json
+func assignValue(dst reflect.Value, value interface{}) error {
+	if _, isNumber := value.(json.Number); isNumber && dst.Kind() == reflect.String && dst.Type() != reflect.TypeOf(value) {
+		// json.Number is a string, but numbers are not converted to strings
+		return &TypeError{Value: value, Type: dst.Type()}
+	}
+	if v, err := convertValue(dst.Type(), value); err == nil {
+		dst.Set(v)
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	res := reflect.New(dst.Type())
+	if err := json.Unmarshal(data, res.Interface()); err != nil {
+		return &TypeError{Value: value, Type: dst.Type()}
+	}
+	dst.Set(res.Elem())
+	return nil
+}
+
+// isEmptyList reports whether path with wildcards or filters matched nothing
+func isEmptyList(res interface{}) bool {
+	arr, ok := res.([]interface{})
+	return ok && len(arr) == 0
+}
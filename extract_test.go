@@ -0,0 +1,161 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type extractCustomer struct {
+	Name  string `jsonpath:"$.profile.name,required"`
+	Email string `jsonpath:"$.contacts[?(@.type == 'email')].value[0]"`
+}
+
+type extractItem struct {
+	SKU string `jsonpath:"$.sku"`
+	Qty int    `jsonpath:"$.qty,default=1"`
+}
+
+type extractMeta struct {
+	Source string `jsonpath:"$.meta.source,default=api, v1"`
+}
+
+type extractOrder struct {
+	extractMeta
+	ID       int64             `jsonpath:"$.order.id,required"`
+	Total    float64           `jsonpath:"$.order.total"`
+	Paid     bool              `jsonpath:"$.order.paid"`
+	Tags     []string          `jsonpath:"$.order.tags"`
+	SKUs     []string          `jsonpath:"$.order.items[*].sku"`
+	First    string            `jsonpath:"$.order.items[0,1].sku[0]"`
+	Address  map[string]string `jsonpath:"$.order.address"`
+	Customer extractCustomer   `jsonpath:"$.order.customer"`
+	Buyer    *extractCustomer  `jsonpath:"$.order.customer"`
+	Items    []extractItem     `jsonpath:"$.order.items"`
+	Priority int               `jsonpath:"$.order.priority,default=5"`
+	Labels   []string          `jsonpath:"$.order.labels,default=[\"new\"]"`
+	Note     string            `jsonpath:"$.order.note"`
+	Ignored  string            `jsonpath:"-"`
+	Untagged string
+}
+
+const extractOrderJSON = `{"order": {
+	"id": 9007199254740993,
+	"total": 10.5,
+	"paid": true,
+	"tags": ["a", "b"],
+	"address": {"city": "Paris"},
+	"customer": {"profile": {"name": "Ann"}, "contacts": [{"type": "phone", "value": "1"}, {"type": "email", "value": "ann@example.com"}]},
+	"items": [{"sku": "x", "qty": 2}, {"sku": "y"}],
+	"note": null
+}}`
+
+func Test_Unmarshal(t *testing.T) {
+	order := extractOrder{Note: "keep", Untagged: "keep"}
+	if err := Unmarshal([]byte(extractOrderJSON), &order); err != nil {
+		t.Fatal(err)
+	}
+	customer := extractCustomer{Name: "Ann", Email: "ann@example.com"}
+	expected := extractOrder{
+		extractMeta: extractMeta{Source: "api, v1"},
+		ID:          9007199254740993,
+		Total:       10.5,
+		Paid:        true,
+		Tags:        []string{"a", "b"},
+		SKUs:        []string{"x", "y"},
+		First:       "x",
+		Address:     map[string]string{"city": "Paris"},
+		Customer:    customer,
+		Buyer:       &customer,
+		Items:       []extractItem{{SKU: "x", Qty: 2}, {SKU: "y", Qty: 1}},
+		Priority:    5,
+		Labels:      []string{"new"},
+		Note:        "keep",
+		Untagged:    "keep",
+	}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %+v\ngot %+v", expected, order)
+	}
+
+	// defaults are not shared between values
+	order.Labels[0] = "changed"
+	var other extractOrder
+	Unmarshal([]byte(extractOrderJSON), &other)
+	if other.Labels[0] != "new" {
+		t.Errorf("default was modified: %v", other.Labels)
+	}
+}
+
+func Test_ExtractErrors(t *testing.T) {
+	data := map[string]interface{}{"order": map[string]interface{}{
+		"total":    "10",
+		"paid":     1.0,
+		"customer": map[string]interface{}{"profile": map[string]interface{}{}},
+		"items":    []interface{}{map[string]interface{}{"sku": "x", "qty": 1.5}},
+	}}
+	var order extractOrder
+	err := Extract(data, &order)
+	extractErr, ok := err.(*ExtractError)
+	if !ok {
+		t.Fatalf("expected ExtractError, got %v", err)
+	}
+	var fields []string
+	for _, e := range extractErr.Errors {
+		fields = append(fields, e.Field)
+	}
+	expected := []string{"ID", "Total", "Paid", "Customer.Name", "Buyer.Name", "Items[0].Qty"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected errors of %v, got %v", expected, err)
+	}
+	if !errors.Is(err, ErrRequired) || !errors.Is(fmt.Errorf("order: %w", err), ErrRequired) {
+		t.Error("expected ErrRequired")
+	}
+	if errors.Is(err, ErrLimitExceeded) {
+		t.Error("unexpected ErrLimitExceeded")
+	}
+	if !strings.HasPrefix(err.Error(), "could not extract 6 fields: field ID ($.order.id): required value not found; ") {
+		t.Errorf("unexpected message %s", err)
+	}
+}
+
+func Test_ExtractInvalidTags(t *testing.T) {
+	var badPath struct {
+		A string `jsonpath:"$.a["`
+	}
+	var badOption struct {
+		A string `jsonpath:"$.a,requred"`
+	}
+	var badDefault struct {
+		A int `jsonpath:"$.a,default=x"`
+	}
+	var unexported struct {
+		a string `jsonpath:"$.a"`
+	}
+	for _, v := range []interface{}{&badPath, &badOption, &badDefault, &unexported} {
+		err := Extract(map[string]interface{}{"a": "1"}, v)
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid jsonpath tag of ") {
+			t.Errorf("%T: unexpected error %v", v, err)
+		}
+	}
+	if err := Extract(map[string]interface{}{}, extractOrder{}); err == nil {
+		t.Error("expected error for non-pointer target")
+	}
+}
+
+func Test_splitTag(t *testing.T) {
+	tcases := []struct {
+		tag, path, opts string
+	}{
+		{"$.a", "$.a", ""},
+		{"$.a[0,1],required", "$.a[0,1]", "required"},
+		{"$.a[?(@.b == 'x,y')].c,default=a,b", "$.a[?(@.b == 'x,y')].c", "default=a,b"},
+	}
+	for _, tcase := range tcases {
+		path, opts := splitTag(tcase.tag)
+		if path != tcase.path || opts != tcase.opts {
+			t.Errorf("%s: unexpected %q %q", tcase.tag, path, opts)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// filter is a filter expression like @.price < $.expensive parsed at compile time
+type filter struct {
+	lp, op, rp string
+	left       *subPath
+	// right is nil when rp is a literal, which is kept in value
+	right *subPath
+	value interface{}
+	pat   *regexp.Regexp
+}
+
+// subPath is a path of filter operand or expression, like @.book[0].price or $.expensive
+type subPath struct {
+	root  bool
+	steps []step
+}
+
+// compileFilter parses filter expression, regexps longer than maxRegexpLength are rejected
+// before compiling, zero maxRegexpLength means no limit
+func compileFilter(expr string, maxRegexpLength int) (*filter, error) {
+	lp, op, rp, err := parse_filter(expr)
+	if err != nil {
+		return nil, err
+	}
+	// regexp is written as /pattern/
+	if op == "=~" && maxRegexpLength > 0 && len(rp)-2 > maxRegexpLength {
+		return nil, regexpLengthError(len(rp)-2, maxRegexpLength)
+	}
+	return newFilter(lp, op, rp)
+}
+
+func newFilter(lp, op, rp string) (*filter, error) {
+	if !checkFilter(lp) {
+		return nil, fmt.Errorf("invalid filter %s", lp)
+	}
+	f := &filter{lp: lp, op: op, rp: rp, value: rp}
+	var err error
+	if f.left, err = compileSubPath(lp); err != nil {
+		return nil, err
+	}
+	switch op {
+	case "exists":
+	case "=~":
+		if f.pat, err = regFilterCompile(rp); err != nil {
+			return nil, err
+		}
+	case "<", "<=", "==", ">=", ">":
+		if checkFilter(rp) {
+			if f.right, err = compileSubPath(rp); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid filter operation \"%s\";should be one of: <, <=, ==, >= and >", op)
+	}
+	return f, nil
+}
+
+// match evaluates filter on obj, errors of missing operands are ignored as Lookup always did
+func (f *filter) match(obj, root interface{}) (bool, error) {
+	lv, err := f.left.get(obj, root)
+	switch f.op {
+	case "exists":
+		return lv != nil, nil
+	case "=~":
+		if err != nil {
+			return false, err
+		}
+		s, ok := lv.(string)
+		if !ok {
+			return false, errors.New("only string can match with regular expression")
+		}
+		return f.pat.MatchString(s), nil
+	}
+	rv := f.value
+	if f.right != nil {
+		rv, _ = f.right.get(obj, root)
+	}
+	return cmpAny(lv, rv, f.op)
+}
+
+// referencesRoot reports whether filter operands are taken from the root object
+func (f *filter) referencesRoot() bool {
+	return f.left.root || (f.right != nil && f.right.root)
+}
+
+func compileSubPath(path string) (*subPath, error) {
+	tokens, err := tokenize(path)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0] != "@" && tokens[0] != "$" {
+		return nil, fmt.Errorf("$ or @ should in front of path")
+	}
+	p := &subPath{
+		root:  tokens[0] == "$",
+		steps: make([]step, 0, len(tokens)-1),
+	}
+	for _, token := range tokens[1:] {
+		op, key, args, err := parse_token(token)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case KeyOp:
+		case IndexOp:
+			if len(args.([]int)) != 1 {
+				return nil, fmt.Errorf("don't support multiple index in filter")
+			}
+		default:
+			return nil, fmt.Errorf("expression don't support in filter")
+		}
+		p.steps = append(p.steps, step{op: op, key: key, args: args})
+	}
+	return p, nil
+}
+
+// get returns value of sub path, obj is used for @ and root for $
+func (p *subPath) get(obj, root interface{}) (interface{}, error) {
+	if p.root {
+		obj = root
+	}
+	var err error
+	for _, s := range p.steps {
+		switch s.op {
+		case KeyOp:
+			var extracted [2]interface{}
+			extracted, err = lookupKey(obj, s)
+			obj = extracted[1]
+		case IndexOp:
+			if s.key != "" {
+				if obj, err = get_key(obj, s.key); err != nil {
+					return nil, err
+				}
+			}
+			obj, err = get_idx(obj, s.args.([]int)[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,113 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlattenOptions configures FlattenWith
+type FlattenOptions struct {
+	// Dotted produces keys like a.b[0] instead of normalized paths like $['a']['b'][0],
+	// keys which could not follow dot are written in brackets like a['x.y']
+	Dotted bool
+}
+
+// Flatten returns normalized paths and values of every leaf of document:
+//
+//	{"a": {"b": [1, 2]}, "c": {}} -> {"$['a']['b'][0]": 1, "$['a']['b'][1]": 2, "$['c']": {}}
+//
+// Leaves are values other than maps and slices, empty maps and empty slices.
+func Flatten(doc interface{}) map[string]interface{} {
+	return FlattenWith(doc, FlattenOptions{})
+}
+
+// FlattenWith is Flatten configured by opts
+func FlattenWith(doc interface{}, opts FlattenOptions) map[string]interface{} {
+	res := map[string]interface{}{}
+	key := location.String
+	if opts.Dotted {
+		key = location.dotted
+	}
+	flatten(res, doc, nil, key)
+	return res
+}
+
+func flatten(res map[string]interface{}, v interface{}, loc location, key func(location) string) {
+	v = followPtr(v)
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice) && rv.Len() > 0 {
+		walkChildren(v, loc, func(child interface{}, childLoc location) error {
+			flatten(res, child, childLoc, key)
+			return nil
+		})
+		return
+	}
+	res[key(loc)] = v
+}
+
+// dotted returns path of the location like a.b[0], root is $
+func (l location) dotted() string {
+	if len(l) == 0 {
+		return "$"
+	}
+	var b strings.Builder
+	for _, e := range l {
+		switch v := e.(type) {
+		case int:
+			b.WriteString("[")
+			b.WriteString(strconv.Itoa(v))
+			b.WriteString("]")
+		case string:
+			if !IsPlainKey(v) {
+				b.WriteString(QuoteKey(v))
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+// Unflatten rebuilds document from paths and values like produced by Flatten.
+// Keys may be paths like $['a']['b'][0] or $.a.b[0] and dotted keys like a.b[0],
+// they should contain only keys and non-negative indexes. Values are stored by Set,
+// missing maps and slices are created and slices are extended with nulls.
+func Unflatten(flat map[string]interface{}) (interface{}, error) {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// the document is built under a key of holder, so root may be a slice
+	holder := map[string]interface{}{}
+	for _, key := range keys {
+		path := "$.root"
+		switch {
+		case key == "$" || key == "":
+		case key[0] == '$':
+			path += key[1:]
+		case key[0] == '[':
+			path += key
+		default:
+			path += "." + key
+		}
+		c, err := DefaultCache.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not unflatten %s: %v", key, err)
+		}
+		if err := setCreating(holder, c, flat[key]); err != nil {
+			return nil, fmt.Errorf("could not unflatten %s: %v", key, err)
+		}
+	}
+	if len(holder) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	return holder["root"], nil
+}
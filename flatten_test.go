@@ -0,0 +1,100 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Flatten(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"a": {"b": [1, {"c": "x"}], "x.y": true}, "e": {}, "f": [], "g": null}`), &doc)
+	expected := map[string]interface{}{
+		"$['a']['b'][0]":      1.0,
+		"$['a']['b'][1]['c']": "x",
+		"$['a']['x.y']":       true,
+		"$['e']":              map[string]interface{}{},
+		"$['f']":              []interface{}{},
+		"$['g']":              nil,
+	}
+	if res := Flatten(doc); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+	expected = map[string]interface{}{
+		"a.b[0]":   1.0,
+		"a.b[1].c": "x",
+		"a['x.y']": true,
+		"e":        map[string]interface{}{},
+		"f":        []interface{}{},
+		"g":        nil,
+	}
+	if res := FlattenWith(doc, FlattenOptions{Dotted: true}); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+
+	for _, v := range []interface{}{"x", []interface{}{}, []interface{}{[]interface{}{1.0}, nil}, map[string]interface{}{"a": 1.0}} {
+		for _, opts := range []FlattenOptions{{}, {Dotted: true}} {
+			res, err := Unflatten(FlattenWith(v, opts))
+			if err != nil || !reflect.DeepEqual(res, v) {
+				t.Errorf("%v: unexpected %v, %v", v, res, err)
+			}
+		}
+	}
+	for _, opts := range []FlattenOptions{{}, {Dotted: true}} {
+		res, err := Unflatten(FlattenWith(doc, opts))
+		if err != nil || !reflect.DeepEqual(res, doc) {
+			t.Errorf("%+v: unexpected %v, %v", opts, res, err)
+		}
+	}
+}
+
+func Test_FlattenEscaping(t *testing.T) {
+	doc := map[string]interface{}{
+		"a]b":  1.0,
+		"it's": []interface{}{map[string]interface{}{"": "empty"}},
+		"a\\b": map[string]interface{}{"c.d": true},
+		"x y":  nil,
+	}
+	expected := map[string]interface{}{
+		"['a]b']":           1.0,
+		"['it\\'s'][0]['']": "empty",
+		"['a\\\\b']['c.d']": true,
+		"['x y']":           nil,
+	}
+	if res := FlattenWith(doc, FlattenOptions{Dotted: true}); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+	for _, opts := range []FlattenOptions{{}, {Dotted: true}} {
+		res, err := Unflatten(FlattenWith(doc, opts))
+		if err != nil || !reflect.DeepEqual(res, doc) {
+			t.Errorf("%+v: unexpected %v, %v", opts, res, err)
+		}
+	}
+}
+
+func Test_Unflatten(t *testing.T) {
+	res, err := Unflatten(map[string]interface{}{
+		"$.a.b[2]": 1,
+		"a.c":      "x",
+		"[0]":      nil,
+	})
+	if err == nil || !strings.Contains(err.Error(), "[0]") {
+		t.Errorf("expected conflict of root types, got %v %v", res, err)
+	}
+
+	res, err = Unflatten(map[string]interface{}{"$.a.b[2]": 1, "a.c": "x"})
+	expected := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{nil, nil, 1}, "c": "x"}}
+	if err != nil || !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, res, err)
+	}
+
+	if res, err := Unflatten(map[string]interface{}{}); err != nil || !reflect.DeepEqual(res, map[string]interface{}{}) {
+		t.Errorf("unexpected %v, %v", res, err)
+	}
+	for _, key := range []string{"$.a[*]", "a[-1]", "a[", "$..a"} {
+		if _, err := Unflatten(map[string]interface{}{key: 1}); err == nil {
+			t.Errorf("%s: expected error", key)
+		}
+	}
+}
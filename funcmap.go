@@ -0,0 +1,69 @@
+package jsonpath
+
+// FuncMap returns functions for text/template and html/template,
+// paths are compiled by DefaultCache:
+//
+//	jsonpath data path         result of Lookup, missing keys are errors
+//	jsonpathAll data path      slice of all matched nodes, empty when nothing matched
+//	jsonpathFirst data path    the first matched node or nil
+//	jsonpathExists data path   whether path matched any node
+//
+// Example:
+//
+//	tmpl := template.Must(template.New("order").Funcs(jsonpath.FuncMap()).Parse(
+//		`{{ range jsonpathAll . "$.order.items[?(@.qty > 1)].sku" }}{{ . }} {{ end }}`))
+func FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"jsonpath":       templateLookup,
+		"jsonpathAll":    templateAll,
+		"jsonpathFirst":  templateFirst,
+		"jsonpathExists": templateExists,
+	}
+}
+
+func templateLookup(obj interface{}, path string) (interface{}, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Lookup(obj)
+}
+
+func templateAll(obj interface{}, path string) ([]interface{}, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	err = c.Each(obj, func(n Node) bool {
+		res = append(res, n.Value)
+		return true
+	})
+	return res, err
+}
+
+func templateFirst(obj interface{}, path string) (interface{}, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	err = c.Each(obj, func(n Node) bool {
+		res = n.Value
+		return false
+	})
+	return res, err
+}
+
+func templateExists(obj interface{}, path string) (bool, error) {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return false, err
+	}
+	exists := false
+	err = c.Each(obj, func(n Node) bool {
+		exists = true
+		return false
+	})
+	return exists, err
+}
@@ -0,0 +1,63 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func Test_FuncMap(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"order": {"id": "A<1>", "items": [
+		{"sku": "x", "qty": 1}, {"sku": "y", "qty": 2}, {"sku": "z", "qty": 3}
+	]}}`), &data)
+
+	tcases := []struct {
+		template string
+		output   string
+	}{
+		{`{{ jsonpath . "$.order.items[?(@.qty > 1)].sku" }}`, "[y z]"},
+		{`{{ jsonpath . "$.order.id" }}`, "A<1>"},
+		{`{{ range jsonpathAll . "$.order.items[*].sku" }}{{ . }},{{ end }}`, "x,y,z,"},
+		{`{{ len (jsonpathAll . "$.order.missing[*]") }}`, "0"},
+		{`{{ jsonpathFirst . "$.order.items[?(@.qty > 1)].sku" }}`, "y"},
+		{`{{ jsonpathFirst . "$.order.missing" }}`, "<no value>"},
+		{`{{ if jsonpathExists . "$.order.id" }}yes{{ end }}`, "yes"},
+		{`{{ if not (jsonpathExists . "$.order.items[?(@.qty > 5)]") }}none{{ end }}`, "none"},
+		{`{{ with jsonpath .order "$.items[0]" }}{{ .sku }}{{ end }}`, "x"},
+	}
+	for _, tcase := range tcases {
+		tmpl := template.Must(template.New("test").Funcs(FuncMap()).Parse(tcase.template))
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			t.Errorf("%s: %v", tcase.template, err)
+			continue
+		}
+		if buf.String() != tcase.output {
+			t.Errorf("%s: expected %q, got %q", tcase.template, tcase.output, buf.String())
+		}
+	}
+
+	for _, text := range []string{
+		`{{ jsonpath . "$.order.missing" }}`,
+		`{{ jsonpathAll . "$.order[" }}`,
+		`{{ jsonpathExists . "order" }}`,
+	} {
+		tmpl := template.Must(template.New("test").Funcs(FuncMap()).Parse(text))
+		if err := tmpl.Execute(&bytes.Buffer{}, data); err == nil {
+			t.Errorf("%s: expected error", text)
+		}
+	}
+
+	html := htmltemplate.Must(htmltemplate.New("html").Funcs(FuncMap()).Parse(`<b>{{ jsonpath . "$.order.id" }}</b>`))
+	var buf bytes.Buffer
+	if err := html.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "A&lt;1&gt;") {
+		t.Errorf("unexpected html %s", buf.String())
+	}
+}
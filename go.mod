@@ -1,3 +1,3 @@
 module github.com/ilyaferilo/jsonpath
 
-go 1.5
+go 1.13
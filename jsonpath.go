@@ -1,10 +1,12 @@
 package jsonpath
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/token"
 	"go/types"
+	"math/big"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -21,6 +23,9 @@ const (
 
 var ErrGetFromNullObj = errors.New("get attribute from null object")
 
+// ErrRootNotAddressable is returned when the root of document should be replaced but it's not a pointer
+var ErrRootNotAddressable = errors.New("root object is not addressable, pass a pointer")
+
 // NotExist is returned by jsonpath.Get on nonexistent paths
 type NotExist struct {
 	key string
@@ -30,23 +35,52 @@ func (d NotExist) Error() string {
 	return fmt.Sprintf("key error: \"%s\" not found in object", d.key)
 }
 
+// IndexOutOfRange is returned when index or bound of range is out of range of slice
+type IndexOutOfRange struct {
+	Len   int
+	Index interface{}
+	// Bound is "from" or "to" for bounds of ranges, empty for indexes
+	Bound string
+}
+
+func (e IndexOutOfRange) Error() string {
+	if e.Bound != "" {
+		return fmt.Sprintf("index [%s] out of range: len: %v, %s: %v", e.Bound, e.Len, e.Bound, e.Index)
+	}
+	return fmt.Sprintf("index out of range: len: %v, idx: %v", e.Len, e.Index)
+}
+
+// IsNotFound reports whether lookup error is caused by missing key, out of range index or null object
+func IsNotFound(err error) bool {
+	var notExist NotExist
+	var outOfRange IndexOutOfRange
+	return errors.As(err, &notExist) || errors.As(err, &outOfRange) || errors.Is(err, ErrGetFromNullObj)
+}
+
 func JsonPathLookup(obj interface{}, jpath string) (interface{}, error) {
-	c, err := Compile(jpath)
+	c, err := DefaultCache.Compile(jpath)
 	if err != nil {
 		return nil, err
 	}
 	return c.Lookup(obj)
 }
 
+// Compiled is a parsed path. It's never modified after Compile,
+// so it could be shared and used by multiple goroutines at once.
 type Compiled struct {
 	path  string
 	steps []step
+	// normalized contains steps with a single selector, see normalizeSteps
+	normalized []step
 }
 
 type step struct {
 	op   string
 	key  string
 	args interface{}
+	// parsed args of filter and expression steps
+	filter *filter
+	expr   *subPath
 }
 
 // MustCompile panic if jpath incorrect
@@ -60,6 +94,11 @@ func MustCompile(jpath string) *Compiled {
 
 // Compile jpath to tokens
 func Compile(jpath string) (*Compiled, error) {
+	return compile(jpath, 0)
+}
+
+// compile parses jpath, regexps of filters longer than maxRegexpLength are rejected before compiling
+func compile(jpath string, maxRegexpLength int) (*Compiled, error) {
 	tokens, err := tokenize(jpath)
 	if err != nil {
 		return nil, err
@@ -77,11 +116,30 @@ func Compile(jpath string) (*Compiled, error) {
 		if err != nil {
 			return nil, err
 		}
-		res.steps[i] = step{op, key, args}
+		s := step{op: op, key: key, args: args}
+		switch op {
+		case FilterOp:
+			s.filter, err = compileFilter(args.(string), maxRegexpLength)
+		case ExpressionOp:
+			s.expr, err = compileSubPath(args.(string))
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.steps[i] = s
 	}
+	res.normalized = normalizeSteps(res.steps)
 	return &res, nil
 }
 
+// selectors returns normalized steps of c
+func (c *Compiled) selectors() []step {
+	if c.normalized != nil {
+		return c.normalized
+	}
+	return normalizeSteps(c.steps)
+}
+
 func (c *Compiled) String() string {
 	return fmt.Sprintf("Compiled lookup: %s", c.path)
 }
@@ -90,6 +148,11 @@ func (c *Compiled) String() string {
 // If step contain subkey then child object of founded object will be returned by subkey
 // returns extracted parent and child
 func lookupKey(obj interface{}, s step) ([2]interface{}, error) {
+	var e *evaluation
+	return e.lookupKey(obj, s)
+}
+
+func (e *evaluation) lookupKey(obj interface{}, s step) ([2]interface{}, error) {
 	parent := obj
 
 	subKey, ok := s.args.(string)
@@ -100,13 +163,13 @@ func lookupKey(obj interface{}, s step) ([2]interface{}, error) {
 		s.key = subKey
 		subKey = ""
 	}
-	obj, err := get_key(obj, s.key)
+	obj, err := e.getKey(obj, s.key)
 	if err != nil {
 		return [2]interface{}{parent, nil}, err
 	}
 	if subKey != "" {
 		parent = obj
-		obj, err = get_key(obj, subKey)
+		obj, err = e.getKey(obj, subKey)
 	}
 	return [2]interface{}{parent, obj}, err
 }
@@ -116,28 +179,34 @@ func lookupExpression(obj, rootObj interface{}, s step) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	key, err := get_lp_v(obj, rootObj, s.args.(string))
+	key, err := s.expr.get(obj, rootObj)
 	if err != nil {
 		return nil, err
 	}
-	switch v := key.(type) {
-	case string:
+	if v, ok := key.(string); ok {
 		return get_key(obj, v)
-	case int:
-		return get_idx(obj, v)
-	default:
-		return nil, fmt.Errorf("extracted invalid expression: %v", v)
 	}
+	if idx, ok := toIndex(key); ok {
+		return get_idx(obj, idx)
+	}
+	return nil, fmt.Errorf("extracted invalid expression: %v", key)
 }
 
 func (c *Compiled) Lookup(rootObj interface{}) (interface{}, error) {
+	return c.lookup(rootObj, nil)
+}
+
+func (c *Compiled) lookup(rootObj interface{}, e *evaluation) (interface{}, error) {
 	obj := rootObj
 	var err error
 	for _, s := range c.steps {
+		if err := e.tick(1); err != nil {
+			return nil, err
+		}
 		// "key", "idx"
 		switch s.op {
 		case KeyOp:
-			parentWithExtracted, err := lookupKey(obj, s)
+			parentWithExtracted, err := e.lookupKey(obj, s)
 			obj = parentWithExtracted[1]
 			if err != nil {
 				return nil, err
@@ -145,13 +214,16 @@ func (c *Compiled) Lookup(rootObj interface{}) (interface{}, error) {
 		case IndexOp:
 			if len(s.key) > 0 {
 				// no key `$[0].test`
-				obj, err = get_key(obj, s.key)
+				obj, err = e.getKey(obj, s.key)
 				if err != nil {
 					return nil, err
 				}
 			}
 
 			if len(s.args.([]int)) > 1 {
+				if err := e.tick(len(s.args.([]int))); err != nil {
+					return nil, err
+				}
 				res := []interface{}{}
 				for _, x := range s.args.([]int) {
 					//fmt.Println("idx ---- ", x)
@@ -175,7 +247,7 @@ func (c *Compiled) Lookup(rootObj interface{}) (interface{}, error) {
 		case RangeOp:
 			if len(s.key) > 0 {
 				// no key `$[:1].test`
-				obj, err = get_key(obj, s.key)
+				obj, err = e.getKey(obj, s.key)
 				if err != nil {
 					return nil, err
 				}
@@ -189,11 +261,11 @@ func (c *Compiled) Lookup(rootObj interface{}) (interface{}, error) {
 				return nil, fmt.Errorf("range args length should be 2")
 			}
 		case FilterOp:
-			obj, err = get_key(obj, s.key)
+			obj, err = e.getKey(obj, s.key)
 			if err != nil {
 				return nil, err
 			}
-			obj, err = get_filtered(obj, rootObj, s.args.(string))
+			obj, err = e.filtered(obj, rootObj, s.filter)
 			if err != nil {
 				return nil, err
 			}
@@ -207,6 +279,9 @@ func (c *Compiled) Lookup(rootObj interface{}) (interface{}, error) {
 		default:
 			return nil, fmt.Errorf("expression don't support in filter")
 		}
+		if err := e.checkResults(obj); err != nil {
+			return nil, err
+		}
 	}
 	return obj, nil
 }
@@ -216,10 +291,27 @@ func tokenize(query string) ([]string, error) {
 	//	token_start := false
 	//	token_end := false
 	token := ""
+	// quoted is set inside of quoted key like ['a]b'], where escaped quotes don't end the key
+	quoted, escaped := false, false
 
 	// fmt.Println("-------------------------------------------------- start")
 	for idx, x := range query {
 		token += string(x)
+		if quoted {
+			switch {
+			case escaped:
+				escaped = false
+			case x == '\\':
+				escaped = true
+			case x == '\'':
+				quoted = false
+			}
+			continue
+		}
+		if x == '\'' && strings.HasSuffix(token, "['") {
+			quoted = true
+			continue
+		}
 		// //fmt.Printf("idx: %d, x: %s, token: %s, tokens: %v\n", idx, string(x), token, tokens)
 		if idx == 0 {
 			if token == "$" || token == "@" {
@@ -326,8 +418,8 @@ func parse_token(token string) (op string, key string, args interface{}, err err
 		tail = tail[1 : len(tail)-1]
 
 		// for ['some.key']
-		if tail[0] == 39 && len(tail) > 2 {
-			return "key", key, tail[1 : len(tail)-1], nil
+		if tail[0] == 39 && len(tail) >= 2 {
+			return "key", key, unquoteKey(tail[1 : len(tail)-1]), nil
 		}
 		if strings.Contains(tail, "?") {
 			// filter -------------------------------------------------
@@ -396,47 +488,26 @@ func parse_token(token string) (op string, key string, args interface{}, err err
 }
 
 func filter_get_from_explicit_path(obj interface{}, path string) (interface{}, error) {
-	steps, err := tokenize(path)
-	//fmt.Println("f: steps: ", steps, err)
-	//fmt.Println(path, steps)
+	p, err := compileSubPath(path)
 	if err != nil {
 		return nil, err
 	}
-	if steps[0] != "@" && steps[0] != "$" {
-		return nil, fmt.Errorf("$ or @ should in front of path")
-	}
-	steps = steps[1:]
-	xobj := obj
-	//fmt.Println("f: xobj", xobj)
-	for _, s := range steps {
-		op, key, args, err := parse_token(s)
-		// "key", "idx"
-		switch op {
-		case KeyOp:
-			xobj, err = get_key(xobj, key)
-			if err != nil {
-				return nil, err
-			}
-		case IndexOp:
-			if len(args.([]int)) != 1 {
-				return nil, fmt.Errorf("don't support multiple index in filter")
-			}
-			xobj, err = get_key(xobj, key)
-			if err != nil {
-				return nil, err
-			}
-			xobj, err = get_idx(xobj, args.([]int)[0])
-			if err != nil {
-				return nil, err
-			}
-		default:
-			return nil, fmt.Errorf("expression don't support in filter")
-		}
-	}
-	return xobj, nil
+	return p.get(obj, obj)
 }
 
 func get_key(obj interface{}, key string) (interface{}, error) {
+	// if obj came from stdlib json, its highly likely to be a map[string]interface{}
+	// or []interface{}, in which case reflection is not needed at all
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		val, exists := v[key]
+		if !exists {
+			return nil, NotExist{key: key}
+		}
+		return val, nil
+	case []interface{}:
+		return get_key_from_slice(v, key)
+	}
 	if reflect.TypeOf(obj) == nil {
 		return nil, ErrGetFromNullObj
 	}
@@ -444,15 +515,8 @@ func get_key(obj interface{}, key string) (interface{}, error) {
 	objType := reflect.TypeOf(obj)
 	switch objType.Kind() {
 	case reflect.Map:
-		// if obj came from stdlib json, its highly likely to be a map[string]interface{}
-		// in which case we can save having to iterate the map keys to work out if the
-		// key exists
 		if jsonMap, ok := obj.(map[string]interface{}); ok {
-			val, exists := jsonMap[key]
-			if !exists {
-				return nil, NotExist{key: key}
-			}
-			return val, nil
+			return get_key(jsonMap, key)
 		}
 		for _, kv := range reflect.ValueOf(obj).MapKeys() {
 			//fmt.Println(kv.String())
@@ -462,11 +526,14 @@ func get_key(obj interface{}, key string) (interface{}, error) {
 		}
 		return nil, NotExist{key: key}
 	case reflect.Slice:
+		if arr, ok := obj.([]interface{}); ok {
+			return get_key_from_slice(arr, key)
+		}
 		// slice we should get from all objects in it.
 		res := []interface{}{}
-		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-			tmp, _ := get_idx(obj, i)
-			if v, err := get_key(tmp, key); err == nil {
+		objVal := reflect.ValueOf(obj)
+		for i := 0; i < objVal.Len(); i++ {
+			if v, err := get_key(objVal.Index(i).Interface(), key); err == nil {
 				res = append(res, v)
 			}
 		}
@@ -479,58 +546,67 @@ func get_key(obj interface{}, key string) (interface{}, error) {
 	}
 }
 
+// get_key_from_slice collects key from all objects of slice
+func get_key_from_slice(arr []interface{}, key string) (interface{}, error) {
+	res := []interface{}{}
+	for _, tmp := range arr {
+		if v, err := get_key(tmp, key); err == nil {
+			res = append(res, v)
+		}
+	}
+	if len(res) == 0 {
+		return nil, NotExist{key: key}
+	}
+	return res, nil
+}
+
 func get_idx(obj interface{}, idx int) (interface{}, error) {
-	if reflect.TypeOf(obj).Kind() != reflect.Slice {
+	if arr, ok := obj.([]interface{}); ok {
+		i, err := sliceIndex(len(arr), idx)
+		if err != nil {
+			return nil, err
+		}
+		return arr[i], nil
+	}
+	if reflect.TypeOf(obj) == nil || reflect.TypeOf(obj).Kind() != reflect.Slice {
 		return nil, fmt.Errorf("object is not Slice")
 	}
 	objVal := reflect.ValueOf(obj)
-	length := objVal.Len()
-	if idx >= 0 {
-		if idx >= length {
-			return nil, fmt.Errorf("index out of range: len: %v, idx: %v", length, idx)
-		}
-		return objVal.Index(idx).Interface(), nil
-	} else {
-		// < 0
-		_idx := length + idx
-		if _idx < 0 {
-			return nil, fmt.Errorf("index out of range: len: %v, idx: %v", length, idx)
-		}
-		return objVal.Index(_idx).Interface(), nil
+	i, err := sliceIndex(objVal.Len(), idx)
+	if err != nil {
+		return nil, err
+	}
+	return objVal.Index(i).Interface(), nil
+}
+
+// sliceIndex converts index, which is negative when counted from the end, to position in slice
+func sliceIndex(length, idx int) (int, error) {
+	_idx := idx
+	if idx < 0 {
+		_idx = length + idx
+	}
+	if _idx < 0 || _idx >= length {
+		return 0, IndexOutOfRange{Len: length, Index: idx}
 	}
+	return _idx, nil
 }
 
 func get_range(obj, frm, to interface{}) (interface{}, error) {
+	if arr, ok := obj.([]interface{}); ok {
+		_frm, _to, err := rangeBounds(len(arr), frm, to)
+		if err != nil {
+			return nil, err
+		}
+		return arr[_frm:_to], nil
+	}
+	if reflect.TypeOf(obj) == nil {
+		return nil, fmt.Errorf("object is not Slice")
+	}
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Slice:
-		length := reflect.ValueOf(obj).Len()
-		_frm := 0
-		_to := length
-		if frm == nil {
-			frm = 0
-		}
-		if to == nil {
-			to = length - 1
-		}
-		if fv, ok := frm.(int); ok {
-			if fv < 0 {
-				_frm = length + fv
-			} else {
-				_frm = fv
-			}
-		}
-		if tv, ok := to.(int); ok {
-			if tv < 0 {
-				_to = length + tv + 1
-			} else {
-				_to = tv + 1
-			}
-		}
-		if _frm < 0 || _frm >= length {
-			return nil, fmt.Errorf("index [from] out of range: len: %v, from: %v", length, frm)
-		}
-		if _to < 0 || _to > length {
-			return nil, fmt.Errorf("index [to] out of range: len: %v, to: %v", length, to)
+		_frm, _to, err := rangeBounds(reflect.ValueOf(obj).Len(), frm, to)
+		if err != nil {
+			return nil, err
 		}
 		//fmt.Println("_frm, _to: ", _frm, _to)
 		res_v := reflect.ValueOf(obj).Slice(_frm, _to)
@@ -540,6 +616,42 @@ func get_range(obj, frm, to interface{}) (interface{}, error) {
 	}
 }
 
+// rangeBounds converts range args to slice bounds, [to] is inclusive
+func rangeBounds(length int, frm, to interface{}) (int, int, error) {
+	_frm := 0
+	_to := length
+	if frm == nil {
+		frm = 0
+	}
+	if to == nil {
+		to = length - 1
+	}
+	if fv, ok := frm.(int); ok {
+		if fv < 0 {
+			_frm = length + fv
+		} else {
+			_frm = fv
+		}
+	}
+	if tv, ok := to.(int); ok {
+		if tv < 0 {
+			_to = length + tv + 1
+		} else {
+			_to = tv + 1
+		}
+	}
+	if _frm < 0 || _frm >= length {
+		return 0, 0, IndexOutOfRange{Len: length, Index: frm, Bound: "from"}
+	}
+	if _to < 0 || _to > length {
+		return 0, 0, IndexOutOfRange{Len: length, Index: to, Bound: "to"}
+	}
+	if _to < _frm {
+		_to = _frm
+	}
+	return _frm, _to, nil
+}
+
 func regFilterCompile(rule string) (*regexp.Regexp, error) {
 	runes := []rune(rule)
 	if len(runes) <= 2 {
@@ -553,78 +665,60 @@ func regFilterCompile(rule string) (*regexp.Regexp, error) {
 	return regexp.Compile(string(runes))
 }
 
-func get_filtered(obj, root interface{}, filter string) ([]interface{}, error) {
-	lp, op, rp, err := parse_filter(filter)
-	if err != nil {
-		return nil, err
-	}
-
+func get_filtered(obj, root interface{}, f *filter) ([]interface{}, error) {
 	res := []interface{}{}
 
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Slice:
-		if op == "=~" {
-			// regexp
-			pat, err := regFilterCompile(rp)
+	switch v := obj.(type) {
+	case []interface{}:
+		for _, tmp := range v {
+			ok, err := f.match(tmp, root)
 			if err != nil {
 				return nil, err
 			}
-
-			for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-				tmp := reflect.ValueOf(obj).Index(i).Interface()
-				ok, err := eval_reg_filter(tmp, root, lp, pat)
-				if err != nil {
-					return nil, err
-				}
-				if ok {
-					res = append(res, tmp)
-				}
-			}
-		} else {
-			for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-				tmp := reflect.ValueOf(obj).Index(i).Interface()
-				ok, err := eval_filter(tmp, root, lp, op, rp)
-				if err != nil {
-					return nil, err
-				}
-				if ok {
-					res = append(res, tmp)
-				}
+			if ok {
+				res = append(res, tmp)
 			}
 		}
 		return res, nil
-	case reflect.Map:
-		if op == "=~" {
-			// regexp
-			pat, err := regFilterCompile(rp)
+	case map[string]interface{}:
+		for _, tmp := range v {
+			ok, err := f.match(tmp, root)
 			if err != nil {
 				return nil, err
 			}
+			if ok {
+				res = append(res, tmp)
+			}
+		}
+		return res, nil
+	}
 
-			for _, kv := range reflect.ValueOf(obj).MapKeys() {
-				tmp := reflect.ValueOf(obj).MapIndex(kv).Interface()
-				ok, err := eval_reg_filter(tmp, root, lp, pat)
-				if err != nil {
-					return nil, err
-				}
-				if ok {
-					res = append(res, tmp)
-				}
+	objVal := reflect.ValueOf(obj)
+	switch objVal.Kind() {
+	case reflect.Slice:
+		for i := 0; i < objVal.Len(); i++ {
+			tmp := objVal.Index(i).Interface()
+			ok, err := f.match(tmp, root)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			for _, kv := range reflect.ValueOf(obj).MapKeys() {
-				tmp := reflect.ValueOf(obj).MapIndex(kv).Interface()
-				ok, err := eval_filter(tmp, root, lp, op, rp)
-				if err != nil {
-					return nil, err
-				}
-				if ok {
-					res = append(res, tmp)
-				}
+			if ok {
+				res = append(res, tmp)
+			}
+		}
+	case reflect.Map:
+		for _, kv := range objVal.MapKeys() {
+			tmp := objVal.MapIndex(kv).Interface()
+			ok, err := f.match(tmp, root)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				res = append(res, tmp)
 			}
 		}
 	default:
-		return nil, fmt.Errorf("don't support filter on this type: %v", reflect.TypeOf(obj).Kind())
+		return nil, fmt.Errorf("don't support filter on this type: %v", objVal.Kind())
 	}
 
 	return res, nil
@@ -760,24 +854,11 @@ func get_lp_v(obj, root interface{}, lp string) (interface{}, error) {
 }
 
 func eval_filter(obj, root interface{}, lp, op, rp string) (res bool, err error) {
-	lp_v, err := get_lp_v(obj, root, lp)
-
-	if op == "exists" {
-		return lp_v != nil, nil
-	} else if op == "=~" {
-		return false, fmt.Errorf("not implemented yet")
-	} else {
-		var rp_v interface{}
-		if strings.HasPrefix(rp, "@.") {
-			rp_v, err = filter_get_from_explicit_path(obj, rp)
-		} else if strings.HasPrefix(rp, "$.") {
-			rp_v, err = filter_get_from_explicit_path(root, rp)
-		} else {
-			rp_v = rp
-		}
-		//fmt.Printf("lp_v: %v, rp_v: %v\n", lp_v, rp_v)
-		return cmpAny(lp_v, rp_v, op)
+	f, err := newFilter(lp, op, rp)
+	if err != nil {
+		return false, err
 	}
+	return f.match(obj, root)
 }
 
 func isNumber(o interface{}) bool {
@@ -792,7 +873,7 @@ func isNumber(o interface{}) bool {
 		_, err := strconv.ParseFloat(v, 64)
 		return err == nil
 	default:
-		return false
+		return isNumeric(o)
 	}
 }
 
@@ -805,6 +886,20 @@ func cmpAny(obj1, obj2 interface{}, op string) (bool, error) {
 
 	var exp string
 	if isNumber(obj1) && isNumber(obj2) {
+		if res, ok := cmpNumbers(obj1, obj2); ok {
+			switch op {
+			case "<":
+				return res < 0, nil
+			case "<=":
+				return res <= 0, nil
+			case "==":
+				return res == 0, nil
+			case ">=":
+				return res >= 0, nil
+			default:
+				return res > 0, nil
+			}
+		}
 		exp = fmt.Sprintf(`%v %s %v`, obj1, op, obj2)
 	} else {
 		exp = fmt.Sprintf(`"%v" %s "%v"`, obj1, op, obj2)
@@ -827,28 +922,41 @@ func cmpAny(obj1, obj2 interface{}, op string) (bool, error) {
 
 func followPtr(data interface{}) interface{} {
 	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Ptr {
+		return data
+	}
 	for rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 	}
+	if !rv.IsValid() {
+		return nil
+	}
 	return rv.Interface()
 }
 
 func stepToPath(s step) string {
 	switch s.op {
 	case KeyOp:
+		path := "$"
+		if s.key != "" {
+			path += QuoteKey(s.key)
+		}
 		if subKey, ok := s.args.(string); ok {
-			return fmt.Sprintf("$.%s['%s']", s.key, subKey)
+			path += QuoteKey(subKey)
 		}
-		return fmt.Sprintf("$.%s", s.key)
+		return path
 	}
 	return "$"
 }
 
 func Set(rootObj interface{}, path string, value interface{}) error {
-	c, err := Compile(path)
+	c, err := DefaultCache.Compile(path)
 	if err != nil {
 		return err
 	}
+	if len(c.steps) == 0 {
+		return fmt.Errorf("could not set root of document, %s", path)
+	}
 
 	obj := followPtr(rootObj)
 	value = followPtr(value)
@@ -897,7 +1005,7 @@ func Set(rootObj interface{}, path string, value interface{}) error {
 			if err != nil {
 				return err
 			}
-			child, err = get_filtered(child, rootObj, s.args.(string))
+			child, err = get_filtered(child, rootObj, s.filter)
 			if err != nil {
 				return err
 			}
@@ -920,30 +1028,39 @@ func Set(rootObj interface{}, path string, value interface{}) error {
 	parentVal := reflect.ValueOf(parent)
 	switch parentVal.Kind() {
 	case reflect.Map:
-
+		// keys are normalized like lookupKey does, parent contains newKey
+		var newValue interface{} = value
+		newKey := last.key
 		if subKey, ok := last.args.(string); ok {
-			var newValue interface{}
-			newKey := last.key
-			newValue = map[string]interface{}{
-				subKey: value,
-			}
-			if notExistsKey, ok := lastError.(NotExist); ok {
-				if notExistsKey.key != newKey {
-					newKey = notExistsKey.key
-					newValue = value
+			if newKey == "" {
+				newKey = subKey
+			} else if notExistsKey, ok := lastError.(NotExist); ok && notExistsKey.key == newKey {
+				newValue = map[string]interface{}{
+					subKey: value,
 				}
+			} else {
+				newKey = subKey
 			}
-			parentVal.SetMapIndex(reflect.ValueOf(newKey), reflect.ValueOf(newValue))
+		}
+		if jsonMap, ok := parent.(map[string]interface{}); ok {
+			jsonMap[newKey] = newValue
 		} else {
-			parentVal.SetMapIndex(reflect.ValueOf(last.key), reflect.ValueOf(value))
+			parentVal.SetMapIndex(reflect.ValueOf(newKey), reflect.ValueOf(newValue))
 		}
 		return nil
 	case reflect.Slice:
 
 		switch last.op {
 		case IndexOp:
-			idx := last.args.([]int)[0]
-			parentVal.Index(idx).Set(reflect.ValueOf(value))
+			idx, err := sliceIndex(parentVal.Len(), last.args.([]int)[0])
+			if err != nil {
+				return err
+			}
+			if arr, ok := parent.([]interface{}); ok {
+				arr[idx] = value
+			} else {
+				parentVal.Index(idx).Set(reflect.ValueOf(value))
+			}
 		case KeyOp:
 			lastStepPath := stepToPath(last)
 			for i := 0; i < parentVal.Len(); i++ {
@@ -966,10 +1083,13 @@ func deleteElement(objSrc interface{}, i int) reflect.Value {
 }
 
 func Del(objSrc interface{}, path string) error {
-	c, err := Compile(path)
+	c, err := DefaultCache.Compile(path)
 	if err != nil {
 		return err
 	}
+	if len(c.steps) == 0 {
+		return fmt.Errorf("could not delete root of document, %s", path)
+	}
 	obj := followPtr(objSrc)
 	child := obj
 	parent := obj
@@ -1017,100 +1137,229 @@ func Del(objSrc interface{}, path string) error {
 		if subKey, ok := last.args.(string); ok {
 			deletedKey = subKey
 		}
-		parentVal.SetMapIndex(reflect.ValueOf(deletedKey), reflect.Value{})
+		if jsonMap, ok := parent.(map[string]interface{}); ok {
+			delete(jsonMap, deletedKey)
+		} else {
+			parentVal.SetMapIndex(reflect.ValueOf(deletedKey), reflect.Value{})
+		}
 	case reflect.Slice:
-		idx := last.args.([]int)[0]
+		idx, err := sliceIndex(parentVal.Len(), last.args.([]int)[0])
+		if err != nil {
+			return err
+		}
+		// element of slice at the root is deleted by replacing the root
+		atRoot := lastStepIdx == 0 && last.key == ""
+		if atRoot && reflect.ValueOf(objSrc).Kind() != reflect.Ptr {
+			return ErrRootNotAddressable
+		}
 		index := strings.LastIndex(path, "[")
+		if arr, ok := parent.([]interface{}); ok {
+			newArr := append(arr[:idx], arr[idx+1:]...)
+			if atRoot {
+				return setRoot(objSrc, reflect.ValueOf(newArr))
+			}
+			return Set(objSrc, path[:index], newArr)
+		}
 		newSlice := deleteElement(parent, idx)
+		if atRoot {
+			return setRoot(objSrc, newSlice)
+		}
 		return Set(objSrc, path[:index], newSlice.Interface())
 	}
 	return nil
 }
 
+// Append adds value to the end of every slice matched by path.
+// If the last key of path doesn't exist in the parent map it is created with value.
+// Slice at the root of document may be extended only when obj is a pointer.
 func Append(obj interface{}, path string, value interface{}) error {
-	c, err := Compile(path)
+	return appendValues(obj, path, []interface{}{value}, value)
+}
+
+// Extend adds values to the end of every slice matched by path, like Append does.
+// Missing last key is created with slice of values.
+func Extend(obj interface{}, path string, values ...interface{}) error {
+	return appendValues(obj, path, values, values)
+}
+
+func appendValues(obj interface{}, path string, values []interface{}, newValue interface{}) error {
+	c, err := DefaultCache.Compile(path)
 	if err != nil {
 		return err
 	}
-
-	obj = followPtr(obj)
-	child := obj
-	parent := obj
-
-	lastStepIdx := len(c.steps) - 1
-
-	for i, s := range c.steps {
-		switch s.op {
-		case KeyOp:
-			parentWithExtracted, _ := lookupKey(parent, s)
-
-			if parentWithExtracted[1] != nil {
-				child = parentWithExtracted[1]
-				parent = parentWithExtracted[0]
-			} else {
-				child = parentWithExtracted[0]
-			}
-
-		case IndexOp:
-			if len(s.key) > 0 {
-				parent, err = get_key(parent, s.key)
-				if err != nil {
-					return err
-				}
-			}
-			if len(s.args.([]int)) == 1 {
-				child, err = get_idx(parent, s.args.([]int)[0])
-				if err != nil {
-					return err
-				}
-			}
-		default:
+	for _, s := range c.steps {
+		if s.op == "scan" {
 			return fmt.Errorf("not support append operation %s", s.op)
 		}
+	}
 
-		if i != lastStepIdx {
-			parent = child
+	var undo undoLog
+	matched := false
+	err = c.walk(obj, func(loc location, v interface{}) error {
+		matched = true
+		sliceVal := reflect.ValueOf(followPtr(v))
+		if sliceVal.Kind() != reflect.Slice {
+			return &AppendError{Path: loc.String(), Value: v}
+		}
+		newSlice := sliceVal
+		for _, value := range values {
+			newVal, err := convertValue(sliceVal.Type().Elem(), value)
+			if err != nil {
+				return err
+			}
+			newSlice = reflect.Append(newSlice, newVal)
 		}
+		if len(loc) == 0 {
+			return setRoot(obj, newSlice)
+		}
+		_, err := updateAtPointer(obj, loc.tokens(), &undo, func(parent interface{}, key string) (interface{}, error) {
+			return setChild(parent, key, newSlice.Interface(), &undo)
+		})
+		return err
+	})
+	if err == nil && !matched {
+		err = appendNewKey(obj, c, newValue, &undo)
 	}
+	if err != nil {
+		undo.rollback()
+		return err
+	}
+	return nil
+}
 
-	last := c.steps[lastStepIdx]
-	updatedKey := last.key
-
-	childVal := reflect.ValueOf(child)
-	parentVal := reflect.ValueOf(parent)
-	if reflect.DeepEqual(childVal, parentVal) {
-		return fmt.Errorf("incorrect object lookup")
+// appendNewKey creates last key of path in every matched parent map
+func appendNewKey(obj interface{}, c *Compiled, value interface{}, undo *undoLog) error {
+	if len(c.steps) == 0 {
+		return NotExist{key: c.path}
 	}
-	var newValue reflect.Value
-	switch childVal.Kind() {
-	case reflect.Map:
-		newKey, ok := last.args.(string)
-		if !ok {
-			return fmt.Errorf("incorrect append operation, key must be a string, got: %v", last.args)
+	last := c.steps[len(c.steps)-1]
+	if last.op != KeyOp {
+		return NotExist{key: c.path}
+	}
+	parents := &Compiled{path: c.path, steps: c.steps[:len(c.steps)-1]}
+	key := last.key
+	if subKey, ok := last.args.(string); ok {
+		if key != "" {
+			parents.steps = append(parents.steps[:len(parents.steps):len(parents.steps)], step{op: KeyOp, key: key})
+		}
+		key = subKey
+	}
+	matched := false
+	err := parents.walk(obj, func(loc location, v interface{}) error {
+		parentVal := reflect.ValueOf(followPtr(v))
+		if parentVal.Kind() != reflect.Map {
+			return nil
+		}
+		matched = true
+		if parentVal.IsNil() {
+			return &AppendError{Path: loc.with(key).String(), Value: parentVal.Interface()}
 		}
-		childVal.SetMapIndex(reflect.ValueOf(newKey), reflect.ValueOf(value))
-		newValue = childVal
-		last.args = nil
+		_, err := setChild(parentVal.Interface(), key, value, undo)
+		return err
+	})
+	if err == nil && !matched {
+		err = NotExist{key: key}
+	}
+	return err
+}
 
-	case reflect.Slice:
-		newValue = reflect.Append(childVal, reflect.ValueOf(value))
-	default:
-		return fmt.Errorf("not support append operation for %v", child)
+// setRoot replaces the value obj points to
+func setRoot(obj interface{}, value reflect.Value) error {
+	rootVal := reflect.ValueOf(obj)
+	if rootVal.Kind() != reflect.Ptr {
+		return ErrRootNotAddressable
 	}
+	for rootVal.Kind() == reflect.Ptr && rootVal.Elem().Kind() == reflect.Ptr {
+		rootVal = rootVal.Elem()
+	}
+	rootVal = rootVal.Elem()
+	if !value.Type().AssignableTo(rootVal.Type()) {
+		return &TypeError{Value: value.Interface(), Type: rootVal.Type()}
+	}
+	rootVal.Set(value)
+	return nil
+}
 
-	switch parentVal.Kind() {
-	case reflect.Map:
+// TypeError is returned when value could not be stored in container with elements of Type
+type TypeError struct {
+	Value interface{}
+	Type  reflect.Type
+}
 
-		newKey, ok := last.args.(string)
-		if ok {
-			updatedKey = newKey
-		}
-		parentVal.SetMapIndex(reflect.ValueOf(updatedKey), newValue)
-	case reflect.Slice:
-		idx := last.args.([]int)[0]
-		parentVal.Index(idx).Set(newValue)
+func (e *TypeError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("could not use null as %v", e.Type)
 	}
+	return fmt.Sprintf("could not use %v (%T) as %v", e.Value, e.Value, e.Type)
+}
 
-	return nil
+// AppendError is returned by Append when matched node is not a slice
+// or missing key could not be created in nil map
+type AppendError struct {
+	Path  string
+	Value interface{}
+}
 
+func (e *AppendError) Error() string {
+	if v := reflect.ValueOf(e.Value); v.Kind() == reflect.Map && v.IsNil() {
+		return fmt.Sprintf("could not append to %s: %T is nil", e.Path, e.Value)
+	}
+	return fmt.Sprintf("could not append to %s: %T is not a slice", e.Path, e.Value)
+}
+
+// convertValue prepare value to be stored in container with elements of type t.
+// Numbers are converted only without loss of precision, slices are converted element-wise.
+func convertValue(t reflect.Type, value interface{}) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Slice, reflect.Ptr:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, &TypeError{Value: value, Type: t}
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	switch value.(type) {
+	case json.Number, *big.Int, *big.Float:
+		if r, ok := toRat(value); ok && isFloatKind(t.Kind()) {
+			f, _ := r.Float64()
+			v = reflect.ValueOf(f)
+		} else if ok && isNumberKind(t.Kind()) {
+			v = reflect.ValueOf(normalizeNumber(value))
+		}
+	}
+	switch {
+	case isNumberKind(v.Kind()) && isNumberKind(t.Kind()):
+		res := v.Convert(t)
+		f1, _ := toFloat(v.Interface())
+		f2, _ := toFloat(res.Interface())
+		if f1 == f2 && res.Convert(v.Type()).Interface() == v.Interface() {
+			return res, nil
+		}
+	case v.Kind() == reflect.String && t.Kind() == reflect.String:
+		return v.Convert(t), nil
+	case v.Kind() == reflect.Slice && t.Kind() == reflect.Slice:
+		res := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := convertValue(t.Elem(), v.Index(i).Interface())
+			if err != nil {
+				return reflect.Value{}, &TypeError{Value: value, Type: t}
+			}
+			res.Index(i).Set(elem)
+		}
+		return res, nil
+	}
+	return reflect.Value{}, &TypeError{Value: value, Type: t}
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
 }
@@ -2,6 +2,7 @@ package jsonpath
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/token"
 	"go/types"
@@ -1086,6 +1087,93 @@ func BenchmarkJsonPathLookup_10(b *testing.B) {
 	}
 }
 
+func BenchmarkLookupCompiledFilter(b *testing.B) {
+	c := MustCompile("$.store.book[?(@.price < $.expensive)]")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := c.Lookup(json_data)
+		if err != nil || len(res.([]interface{})) != 2 {
+			b.Fatalf("unexpected result: %v, %v", res, err)
+		}
+	}
+}
+
+func BenchmarkLookupCompiledRegexp(b *testing.B) {
+	c := MustCompile("$.store.book[?(@.author =~ /(?i).*REES/)].price")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Lookup(json_data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLookupCompiledExpression(b *testing.B) {
+	c := MustCompile("$.store[($.main)]")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Lookup(json_data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkLookupAllocs(b *testing.B, path string) {
+	c := MustCompile(path)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Lookup(json_data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLookupAllocsIndex(b *testing.B) {
+	benchmarkLookupAllocs(b, "$.store.book[-1].price")
+}
+
+func BenchmarkLookupAllocsRange(b *testing.B) {
+	benchmarkLookupAllocs(b, "$.store.book[1:2].title")
+}
+
+func BenchmarkLookupAllocsWildcard(b *testing.B) {
+	benchmarkLookupAllocs(b, "$.store.book[*].author")
+}
+
+func BenchmarkLookupAllocsFilter(b *testing.B) {
+	benchmarkLookupAllocs(b, "$.store.book[?(@.author == 'Nigel Rees')].price")
+}
+
+func BenchmarkSetAllocs(b *testing.B) {
+	data := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1, 2, 3}}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Set(data, "$.a.b[1]", 5); err != nil {
+			b.Fatal(err)
+		}
+		if err := Set(data, "$.a.c", 5); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDelAllocs(b *testing.B) {
+	data := map[string]interface{}{"a": map[string]interface{}{"b": 1}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data["a"].(map[string]interface{})["b"] = 1
+		if err := Del(data, "$.a.b"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestReg(t *testing.T) {
 	r := regexp.MustCompile(`(?U).*REES`)
 	t.Log(r)
@@ -1431,6 +1519,81 @@ func Test_DeleteFromSlice(t *testing.T) {
 	}
 }
 
+func Test_IsNotFound(t *testing.T) {
+	var obj interface{}
+	json.Unmarshal([]byte(`{"a": [1, 2], "n": null, "s": "x"}`), &obj)
+	for _, path := range []string{"$.b", "$.a[5]", "$.a[-3]", "$.a[3:]", "$.n.b"} {
+		_, err := JsonPathLookup(obj, path)
+		if !IsNotFound(err) {
+			t.Errorf("%s: expected not found error, got %v", path, err)
+		}
+		if !IsNotFound(fmt.Errorf("wrapped: %w", err)) {
+			t.Errorf("%s: expected wrapped not found error", path)
+		}
+	}
+	_, err := JsonPathLookup(obj, "$.a[5]")
+	var outOfRange IndexOutOfRange
+	if !errors.As(err, &outOfRange) || outOfRange.Len != 2 || outOfRange.Index != 5 {
+		t.Errorf("unexpected error %#v", err)
+	}
+	for _, err := range []error{nil, errors.New("value out of range"), ErrLimitExceeded} {
+		if IsNotFound(err) {
+			t.Errorf("unexpected not found error %v", err)
+		}
+	}
+}
+
+func Test_SetQuotedKeys(t *testing.T) {
+	obj := map[string]interface{}{"a": 0, "b": map[string]interface{}{"c": 1}}
+	for path, value := range map[string]interface{}{
+		"$['a']":       1,
+		"$.b['c']":     2,
+		"$['b']['d']":  3,
+		"$.e['f']":     4,
+		`$['it\'s']`:   5,
+		`$.b['x]\\y']`: 6,
+	} {
+		if err := Set(obj, path, value); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	expected := map[string]interface{}{
+		"a":    1,
+		"b":    map[string]interface{}{"c": 2, "d": 3, "x]\\y": 6},
+		"e":    map[string]interface{}{"f": 4},
+		"it's": 5,
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("expected %v, got %v", expected, obj)
+	}
+}
+
+func Test_SetDelRoot(t *testing.T) {
+	var root interface{} = []interface{}{1, 2, 3}
+	if err := Set(&root, "$", 1); err == nil {
+		t.Error("expected error on setting root")
+	}
+	if err := Del(&root, "$"); err == nil {
+		t.Error("expected error on deleting root")
+	}
+	if err := Del(root, "$[0]"); err != ErrRootNotAddressable {
+		t.Errorf("expected ErrRootNotAddressable, got: %v", err)
+	}
+	if err := Del(&root, "$[0]"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(root, []interface{}{2, 3}) {
+		t.Errorf("unexpected root %v", root)
+	}
+	ints := []int{1, 2}
+	if err := Del(&ints, "$[1]"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ints, []int{1}) {
+		t.Errorf("unexpected root %v", ints)
+	}
+}
+
 func Test_Append(t *testing.T) {
 	var data = map[string]interface{}{
 		"values": []int{
@@ -1574,6 +1737,21 @@ func Test_LookupBadQuery(t *testing.T) {
 	t.Log(res, err)
 }
 
+func Test_CompileBadFilter(t *testing.T) {
+	for _, path := range []string{
+		"$.store.book[?(@.author =~ /(?i.*REES/)]",
+		"$.store.book[?(@.author =~ REES)]",
+		"$.store.book[?(@.price != 10)]",
+		"$.store.book[?(@.price < $.items[0,1])]",
+		"$.store.book[?(@.price < @.items[1:2])]",
+		"$.store[($.main[0,1])]",
+	} {
+		if _, err := Compile(path); err == nil {
+			t.Errorf("%s: expected compile error", path)
+		}
+	}
+}
+
 func Test_LookupExpresion(t *testing.T) {
 
 	res, err := JsonPathLookup(json_data, "$.store[($.main)]")
@@ -1589,3 +1767,78 @@ func Test_LookupExpresion(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_AppendNested(t *testing.T) {
+	var root interface{}
+	json.Unmarshal([]byte(`[[1], [2], {"books": [{"price": 5, "tags": []}, {"price": 15, "tags": ["a"]}]}]`), &root)
+
+	if err := Append(&root, "$", "last"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(root, "$", "last"); err != ErrRootNotAddressable {
+		t.Errorf("expected ErrRootNotAddressable, got: %v", err)
+	}
+	if err := Append(root, "$[0]", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(root, "$[2].books[?(@.price > 10)].tags", "b"); err != nil {
+		t.Fatal(err)
+	}
+	var exp interface{}
+	json.Unmarshal([]byte(`[[1, 1.5], [2], {"books": [{"price": 5, "tags": []}, {"price": 15, "tags": ["a", "b"]}]}, "last"]`), &exp)
+	if !reflect.DeepEqual(root, exp) {
+		t.Errorf("got: %v", root)
+	}
+
+	if err := Append(root, "$[2].books[0].price", 1); err == nil {
+		t.Error("expected error")
+	} else if _, ok := err.(*AppendError); !ok {
+		t.Errorf("expected *AppendError, got: %v", err)
+	}
+}
+
+func Test_AppendTyped(t *testing.T) {
+	root := []int{1}
+	if err := Append(&root, "$", 2.0); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"ints": []int{1},
+		"strs": [][]string{{"a"}},
+	}
+	if err := Extend(&data, "$.ints", 2, 3.0, uint8(4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(&data, "$.strs", []interface{}{"b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Extend(&data, "$.new", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(root, []int{1, 2}) ||
+		!reflect.DeepEqual(data["ints"], []int{1, 2, 3, 4}) ||
+		!reflect.DeepEqual(data["strs"], [][]string{{"a"}, {"b", "c"}}) ||
+		!reflect.DeepEqual(data["new"], []interface{}{1, 2}) {
+		t.Errorf("got: %v %v", root, data)
+	}
+
+	for _, value := range []interface{}{"x", 2.5, uint64(1) << 63, nil} {
+		err := Append(&data, "$.ints", value)
+		if _, ok := err.(*TypeError); !ok {
+			t.Errorf("%v: expected *TypeError, got: %v", value, err)
+		}
+	}
+	if err := Extend(&data, "$.ints", 5, "x"); err == nil {
+		t.Error("expected error")
+	}
+	if !reflect.DeepEqual(data["ints"], []int{1, 2, 3, 4}) {
+		t.Errorf("failed append modified slice: %v", data["ints"])
+	}
+
+	nilMaps := map[string]interface{}{"m": map[string][]int(nil)}
+	if err := Append(&nilMaps, "$.m.new", 1); err == nil {
+		t.Error("expected error for nil map")
+	} else if _, ok := err.(*AppendError); !ok {
+		t.Errorf("expected *AppendError, got: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package jsonpath
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LinesOptions configures LookupLines
+type LinesOptions struct {
+	// ContinueOnError reports malformed lines as results instead of stopping iteration
+	ContinueOnError bool
+	// Workers is the number of goroutines evaluating lines, results keep input order.
+	// Lines are evaluated sequentially when Workers <= 1.
+	Workers int
+}
+
+// LineResult is the result of path lookup in a single line
+type LineResult struct {
+	Line  int
+	Value interface{}
+	Err   error
+}
+
+// LineError is reported for lines which are not valid JSON
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// LinesIterator iterates over lookup results of newline-delimited JSON documents.
+//
+//	it := c.LookupLines(r, jsonpath.LinesOptions{})
+//	defer it.Close()
+//	for it.Next() {
+//		res := it.Result()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type LinesIterator struct {
+	c    *Compiled
+	opts LinesOptions
+	r    *bufio.Reader
+	line int
+	cur  LineResult
+	err  error
+	done bool
+
+	// parallel evaluation
+	queue chan *lineJob
+	stop  chan struct{}
+	once  sync.Once
+}
+
+type lineJob struct {
+	line    int
+	data    []byte
+	res     LineResult
+	readErr error
+	done    chan struct{}
+}
+
+// LookupLines evaluates path for every line of newline-delimited JSON read from r.
+// Empty lines are skipped. Lookup errors are reported in LineResult.Err, malformed
+// lines stop iteration unless opts.ContinueOnError is set.
+func (c *Compiled) LookupLines(r io.Reader, opts LinesOptions) *LinesIterator {
+	it := &LinesIterator{
+		c:    c,
+		opts: opts,
+		r:    bufio.NewReader(r),
+	}
+	if opts.Workers > 1 {
+		it.start()
+	}
+	return it
+}
+
+// Next advances iterator to the next result
+func (it *LinesIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	var res LineResult
+	if it.queue == nil {
+		line, data, err := it.readLine()
+		if err != nil {
+			return it.finish(err)
+		}
+		res = it.evaluate(line, data)
+	} else {
+		job, ok := <-it.queue
+		if !ok {
+			return it.finish(io.EOF)
+		}
+		<-job.done
+		if job.readErr != nil {
+			return it.finish(job.readErr)
+		}
+		res = job.res
+	}
+	if _, ok := res.Err.(*LineError); ok && !it.opts.ContinueOnError {
+		return it.finish(res.Err)
+	}
+	it.cur = res
+	return true
+}
+
+// Result returns the current result
+func (it *LinesIterator) Result() LineResult {
+	return it.cur
+}
+
+// Err returns error which stopped iteration: read error or malformed line
+func (it *LinesIterator) Err() error {
+	return it.err
+}
+
+// Close stops iteration and releases workers
+func (it *LinesIterator) Close() {
+	it.done = true
+	it.once.Do(func() {
+		if it.stop != nil {
+			close(it.stop)
+		}
+	})
+}
+
+func (it *LinesIterator) finish(err error) bool {
+	if err != io.EOF {
+		it.err = err
+	}
+	it.cur = LineResult{}
+	it.Close()
+	return false
+}
+
+// readLine returns the next non empty line with its number
+func (it *LinesIterator) readLine() (int, []byte, error) {
+	for {
+		data, err := it.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return 0, nil, err
+		}
+		it.line++
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			return it.line, data, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (it *LinesIterator) evaluate(line int, data []byte) LineResult {
+	var obj interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return LineResult{Line: line, Err: &LineError{Line: line, Err: err}}
+	}
+	v, err := it.c.Lookup(obj)
+	return LineResult{Line: line, Value: v, Err: err}
+}
+
+// start runs reader goroutine and workers, jobs are queued in input order
+func (it *LinesIterator) start() {
+	it.queue = make(chan *lineJob, it.opts.Workers*4)
+	it.stop = make(chan struct{})
+	jobs := make(chan *lineJob)
+
+	go func() {
+		defer close(it.queue)
+		defer close(jobs)
+		for {
+			line, data, err := it.readLine()
+			if err == io.EOF {
+				return
+			}
+			job := &lineJob{line: line, data: data, done: make(chan struct{})}
+			if err != nil {
+				job.readErr = err
+				close(job.done)
+			}
+			select {
+			case it.queue <- job:
+			case <-it.stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-it.stop:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < it.opts.Workers; i++ {
+		go func() {
+			for job := range jobs {
+				job.res = it.evaluate(job.line, job.data)
+				close(job.done)
+			}
+		}()
+	}
+}
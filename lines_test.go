@@ -0,0 +1,95 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func linesTestInput(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		switch {
+		case i%10 == 3:
+			b.WriteString("{broken\n")
+		case i%10 == 5:
+			b.WriteString("\n")
+		case i%10 == 7:
+			b.WriteString(`{"other": 1}` + "\n")
+		default:
+			fmt.Fprintf(&b, `{"id": %d, "tags": ["t%d"]}`+"\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func collectLines(it *LinesIterator) ([]LineResult, error) {
+	defer it.Close()
+	var res []LineResult
+	for it.Next() {
+		res = append(res, it.Result())
+	}
+	return res, it.Err()
+}
+
+func Test_LookupLines(t *testing.T) {
+	c := MustCompile("$.tags[0]")
+	input := linesTestInput(100)
+
+	seq, err := collectLines(c.LookupLines(strings.NewReader(input), LinesOptions{ContinueOnError: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seq) != 90 {
+		t.Fatalf("unexpected number of results: %d", len(seq))
+	}
+	for _, res := range seq {
+		i := res.Line - 1
+		switch i % 10 {
+		case 3:
+			if _, ok := res.Err.(*LineError); !ok {
+				t.Errorf("line %d: expected LineError, got: %v", res.Line, res.Err)
+			}
+		case 7:
+			if _, ok := res.Err.(NotExist); !ok {
+				t.Errorf("line %d: expected NotExist, got: %v", res.Line, res.Err)
+			}
+		default:
+			if res.Err != nil || res.Value != fmt.Sprintf("t%d", i) {
+				t.Errorf("line %d: unexpected result: %v", res.Line, res)
+			}
+		}
+	}
+
+	par, err := collectLines(c.LookupLines(strings.NewReader(input), LinesOptions{ContinueOnError: true, Workers: 4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seq, par) {
+		t.Error("parallel results differ from sequential")
+	}
+}
+
+func Test_LookupLinesStop(t *testing.T) {
+	c := MustCompile("$.id")
+	for _, workers := range []int{0, 3} {
+		res, err := collectLines(c.LookupLines(strings.NewReader(linesTestInput(20)), LinesOptions{Workers: workers}))
+		lineErr, ok := err.(*LineError)
+		if !ok || lineErr.Line != 4 {
+			t.Errorf("workers %d: expected error at line 4, got: %v", workers, err)
+		}
+		if len(res) != 3 {
+			t.Errorf("workers %d: unexpected results: %v", workers, res)
+		}
+	}
+
+	it := c.LookupLines(strings.NewReader(linesTestInput(1000)), LinesOptions{Workers: 8, ContinueOnError: true})
+	if !it.Next() || it.Result().Value != 0.0 {
+		t.Errorf("unexpected first result: %v", it.Result())
+	}
+	it.Close()
+	if it.Next() {
+		t.Error("iteration continued after Close")
+	}
+}
@@ -0,0 +1,163 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshal builds document from fields of struct v by paths of their jsonpath tags,
+// it's the reverse of Extract:
+//
+//	type Request struct {
+//		Name  string `jsonpath:"$.user.profile.name"`
+//		Email string `jsonpath:"$.user.contacts[0].value,omitempty"`
+//	}
+//	doc, err := jsonpath.Marshal(Request{Name: "Ann"}) // {"user": {"profile": {"name": "Ann"}}}
+//
+// Paths should contain only keys and non-negative indexes. Values are stored by Set,
+// missing maps and slices on the path are created, slices are extended with nulls.
+// Fields of struct types with jsonpath tags, pointers and slices of them are marshaled
+// recursively to nested documents. Fields with omitempty option are skipped when they
+// have zero values, required and default options are ignored.
+func Marshal(v interface{}) (map[string]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("marshal source should be a struct, got %T", v)
+	}
+	doc := map[string]interface{}{}
+	if err := marshalStruct(doc, rv, ""); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func marshalStruct(doc map[string]interface{}, rv reflect.Value, prefix string) error {
+	plan := planOf(rv.Type())
+	if plan.err != nil {
+		return plan.err
+	}
+	for _, f := range plan.fields {
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + f.name
+		}
+		field := rv.FieldByIndex(f.index)
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+		value, err := marshalValue(field, name)
+		if err != nil {
+			return err
+		}
+		if err := setCreating(doc, f.path, value); err != nil {
+			return &FieldError{Field: name, Path: f.path.path, Err: err}
+		}
+	}
+	return nil
+}
+
+// marshalValue returns value of field, structs with jsonpath tags are marshaled to documents
+func marshalValue(v reflect.Value, name string) (interface{}, error) {
+	t := v.Type()
+	switch {
+	case hasTags(t):
+		doc := map[string]interface{}{}
+		if err := marshalStruct(doc, v, name); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	case t.Kind() == reflect.Ptr && hasTags(t.Elem()):
+		if v.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(v.Elem(), name)
+	case t.Kind() == reflect.Slice && (hasTags(t.Elem()) || t.Elem().Kind() == reflect.Ptr && hasTags(t.Elem().Elem())):
+		if v.IsNil() {
+			return nil, nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			elem, err := marshalValue(v.Index(i), fmt.Sprintf("%s[%d]", name, i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = elem
+		}
+		return res, nil
+	}
+	return v.Interface(), nil
+}
+
+// setCreating stores value by path like Set, missing maps and slices on the path
+// are created and slices are extended with nulls up to index
+func setCreating(doc map[string]interface{}, c *Compiled, value interface{}) error {
+	steps := c.selectors()
+	if len(steps) == 0 {
+		return fmt.Errorf("could not set root of document")
+	}
+	for _, s := range steps {
+		switch {
+		case s.op == KeyOp:
+		case s.op == IndexOp && len(s.args.([]int)) == 1 && s.args.([]int)[0] >= 0:
+		default:
+			return fmt.Errorf("path %s should contain only keys and non-negative indexes", c.path)
+		}
+	}
+
+	var cur interface{} = doc
+	// replace stores extended slice in its parent
+	replace := func(interface{}) {}
+	for i, s := range steps {
+		last := i == len(steps)-1
+		var child interface{}
+		switch s.op {
+		case KeyOp:
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("could not set key %q of %T", s.key, cur)
+			}
+			if last {
+				break
+			}
+			child = m[s.key]
+			if child == nil {
+				child = newContainer(steps[i+1])
+				m[s.key] = child
+			}
+			key := s.key
+			replace = func(v interface{}) { m[key] = v }
+		case IndexOp:
+			arr, ok := cur.([]interface{})
+			if !ok {
+				return fmt.Errorf("could not set index %d of %T", s.args.([]int)[0], cur)
+			}
+			idx := s.args.([]int)[0]
+			if idx >= len(arr) {
+				arr = append(arr, make([]interface{}, idx+1-len(arr))...)
+				replace(arr)
+			}
+			if last {
+				break
+			}
+			child = arr[idx]
+			if child == nil {
+				child = newContainer(steps[i+1])
+				arr[idx] = child
+			}
+			replace = func(v interface{}) { arr[idx] = v }
+		}
+		cur = child
+	}
+	return Set(doc, c.path, value)
+}
+
+// newContainer returns empty map for key step and empty slice for index step
+func newContainer(s step) interface{} {
+	if s.op == IndexOp {
+		return []interface{}{}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,83 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type marshalContact struct {
+	Type  string `jsonpath:"$.type"`
+	Value string `jsonpath:"$.value"`
+}
+
+type marshalRequest struct {
+	Name     string           `jsonpath:"$.user.profile.name"`
+	Age      int              `jsonpath:"$.user.profile.age,omitempty"`
+	Nickname *string          `jsonpath:"$.user.profile['nick.name']"`
+	Phone    string           `jsonpath:"$.user.contacts[0].value"`
+	Email    string           `jsonpath:"$.user.contacts[2].value"`
+	Tags     []string         `jsonpath:"$.tags"`
+	Extra    []marshalContact `jsonpath:"$.extra,omitempty"`
+	Primary  *marshalContact  `jsonpath:"$.primary"`
+	Ignored  string           `jsonpath:"-"`
+	Untagged string
+}
+
+func Test_Marshal(t *testing.T) {
+	nick := "annie"
+	req := marshalRequest{
+		Name:     "Ann",
+		Nickname: &nick,
+		Phone:    "123",
+		Email:    "ann@example.com",
+		Tags:     []string{"a"},
+		Extra:    []marshalContact{{Type: "fax", Value: "456"}},
+		Ignored:  "x",
+		Untagged: "x",
+	}
+	doc, err := Marshal(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(doc)
+	expected := `{"extra":[{"type":"fax","value":"456"}],"primary":null,"tags":["a"],` +
+		`"user":{"contacts":[{"value":"123"},null,{"value":"ann@example.com"}],"profile":{"name":"Ann","nick.name":"annie"}}}`
+	if string(data) != expected {
+		t.Errorf("expected %s\ngot %s", expected, data)
+	}
+
+	var res marshalRequest
+	if err := Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	req.Ignored, req.Untagged = "", ""
+	if !reflect.DeepEqual(res, req) {
+		t.Errorf("expected %+v\ngot %+v", req, res)
+	}
+}
+
+func Test_MarshalErrors(t *testing.T) {
+	var filter struct {
+		A string `jsonpath:"$.a[?(@.b == 1)]"`
+	}
+	var negative struct {
+		A string `jsonpath:"$.a[-1]"`
+	}
+	var conflict struct {
+		A string `jsonpath:"$.a"`
+		B string `jsonpath:"$.a.b"`
+	}
+	var root struct {
+		A string `jsonpath:"$"`
+	}
+	for _, v := range []interface{}{filter, negative, conflict, &root} {
+		if _, err := Marshal(v); err == nil || !strings.HasPrefix(err.Error(), "field ") {
+			t.Errorf("%T: unexpected error %v", v, err)
+		}
+	}
+	if _, err := Marshal("x"); err == nil {
+		t.Error("expected error for non-struct")
+	}
+}
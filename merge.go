@@ -0,0 +1,117 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergePatch applies RFC 7386 JSON Merge Patch to target and returns the result.
+// Maps of target are modified in place, null values of patch delete keys,
+// objects are merged recursively and any other value replaces the target.
+func MergePatch(target, patch interface{}) (interface{}, error) {
+	var undo undoLog
+	res, err := mergePatch(target, patch, &undo)
+	if err != nil {
+		undo.rollback()
+		return target, err
+	}
+	return res, nil
+}
+
+// MergeAt applies JSON Merge Patch to every node matched by path.
+// Matched objects are merged in place, other nodes are replaced by the patch result.
+// On error the document is left unchanged.
+func MergeAt(obj interface{}, path string, patch interface{}) error {
+	c, err := DefaultCache.Compile(path)
+	if err != nil {
+		return err
+	}
+
+	type match struct {
+		loc   location
+		value interface{}
+	}
+	var matches []match
+	err = c.walk(obj, func(loc location, v interface{}) error {
+		matches = append(matches, match{loc, v})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return NotExist{key: path}
+	}
+
+	var undo undoLog
+	for _, m := range matches {
+		if err := mergeAtLocation(obj, m.loc, m.value, patch, &undo); err != nil {
+			undo.rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeAtLocation(obj interface{}, loc location, target, patch interface{}, undo *undoLog) error {
+	merged, err := mergePatch(target, patch, undo)
+	if err != nil {
+		return err
+	}
+	if isMergeable(target, patch) {
+		return nil
+	}
+	if len(loc) == 0 {
+		return fmt.Errorf("could not replace root object by merge patch")
+	}
+	_, err = updateAtPointer(obj, loc.tokens(), undo, func(parent interface{}, key string) (interface{}, error) {
+		return setChild(parent, key, merged, undo)
+	})
+	return err
+}
+
+// isMergeable reports whether patch can be merged into target in place
+func isMergeable(target, patch interface{}) bool {
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return false
+	}
+	targetVal := reflect.ValueOf(followPtr(target))
+	return targetVal.Kind() == reflect.Map && !targetVal.IsNil() && targetVal.Type().Key().Kind() == reflect.String
+}
+
+func mergePatch(target, patch interface{}, undo *undoLog) (interface{}, error) {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch, nil
+	}
+	if !isMergeable(target, patch) {
+		target = map[string]interface{}{}
+	}
+	target = followPtr(target)
+	targetVal := reflect.ValueOf(target)
+	keyType := targetVal.Type().Key()
+	for name, value := range patchMap {
+		key := reflect.ValueOf(name).Convert(keyType)
+		if value == nil {
+			undo.setMapIndex(targetVal, key, reflect.Value{})
+			continue
+		}
+		var old interface{}
+		if oldVal := targetVal.MapIndex(key); oldVal.IsValid() {
+			old = oldVal.Interface()
+		}
+		merged, err := mergePatch(old, value, undo)
+		if err != nil {
+			return nil, err
+		}
+		if isMergeable(old, value) {
+			continue
+		}
+		newVal, err := convertValue(targetVal.Type().Elem(), merged)
+		if err != nil {
+			return nil, fmt.Errorf("could not merge key %q: %v", name, err)
+		}
+		undo.setMapIndex(targetVal, key, newVal)
+	}
+	return target, nil
+}
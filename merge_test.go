@@ -0,0 +1,117 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var merge_patch_cases = []struct {
+	target string
+	patch  string
+	exp    string
+}{
+	{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+	{`{"a":"b"}`, `{"a":null}`, `{}`},
+	{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+	{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+	{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+	{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+	{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+	{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+	{`{"a":"b"}`, `["c"]`, `["c"]`},
+	{`{"a":"foo"}`, `null`, `null`},
+	{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+	{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+}
+
+func Test_MergePatch(t *testing.T) {
+	for idx, tcase := range merge_patch_cases {
+		var target, patch, exp interface{}
+		json.Unmarshal([]byte(tcase.target), &target)
+		json.Unmarshal([]byte(tcase.patch), &patch)
+		json.Unmarshal([]byte(tcase.exp), &exp)
+		res, err := MergePatch(target, patch)
+		if err != nil {
+			t.Errorf("[%d] %v", idx, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, exp) {
+			t.Errorf("[%d] got: %v, expected: %v", idx, res, exp)
+		}
+	}
+}
+
+func Test_MergeAt(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{
+		"spec": {
+			"template": {"image": "nginx", "ports": [80], "env": {"A": "1", "B": "2"}},
+			"items": [{"kind": "a", "n": 1}, {"kind": "b", "n": 2}, {"kind": "a", "n": 3}],
+			"name": "x"
+		}
+	}`), &data)
+
+	var patch interface{}
+	json.Unmarshal([]byte(`{"image": "nginx:1.19", "ports": [443], "env": {"A": null, "C": "3"}}`), &patch)
+	if err := MergeAt(&data, "$.spec.template", patch); err != nil {
+		t.Fatal(err)
+	}
+	var exp interface{}
+	json.Unmarshal([]byte(`{"image": "nginx:1.19", "ports": [443], "env": {"B": "2", "C": "3"}}`), &exp)
+	if res, _ := JsonPathLookup(data, "$.spec.template"); !reflect.DeepEqual(res, exp) {
+		t.Errorf("got: %v", res)
+	}
+
+	if err := MergeAt(data, "$.spec.items[?(@.kind == 'a')]", map[string]interface{}{"n": nil, "m": true}); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(`[{"kind": "a", "m": true}, {"kind": "b", "n": 2}, {"kind": "a", "m": true}]`), &exp)
+	if res, _ := JsonPathLookup(data, "$.spec.items"); !reflect.DeepEqual(res, exp) {
+		t.Errorf("got: %v", res)
+	}
+
+	// non object target is replaced
+	if err := MergeAt(data, "$.spec.name", map[string]interface{}{"first": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookup(data, "$.spec.name.first"); res != "y" {
+		t.Errorf("got: %v", res)
+	}
+	if err := MergeAt(data, "$.spec.items[1]", "replaced"); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookup(data, "$.spec.items[1]"); res != "replaced" {
+		t.Errorf("got: %v", res)
+	}
+
+	if err := MergeAt(data, "$.spec.missing", patch); err == nil {
+		t.Error("expected error for missing path")
+	}
+	if err := MergeAt(data, "$", "root"); err == nil {
+		t.Error("expected error for replacing root")
+	}
+}
+
+func Test_MergeAtRollback(t *testing.T) {
+	data := map[string]interface{}{
+		"a": map[string]interface{}{"x": 1},
+		"b": map[string]string{"x": "1"},
+	}
+	patch := map[string]interface{}{"x": map[string]interface{}{"y": 2}, "z": nil}
+	if err := MergeAt(data, "$.b", map[string]interface{}{"x": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeAt(data, "$.a", patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeAt(data, "$.b", map[string]interface{}{"q": "1", "x": map[string]interface{}{}}); err == nil {
+		t.Error("expected conversion error")
+	}
+	if _, found := data["b"].(map[string]string)["q"]; found {
+		t.Error("merge was not rolled back")
+	}
+}
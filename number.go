@@ -0,0 +1,167 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// isNumeric reports whether o is a number value, numeric strings are not included
+func isNumeric(o interface{}) bool {
+	switch v := o.(type) {
+	case json.Number:
+		_, ok := toRat(v)
+		return ok
+	case *big.Int:
+		return v != nil
+	case *big.Float:
+		return v != nil && !v.IsInf()
+	default:
+		return isNumberKind(reflect.ValueOf(o).Kind())
+	}
+}
+
+// toRat converts number or numeric string to exact rational.
+// Binary floats are taken by their shortest decimal representation,
+// so 8.95 is equal to "8.95".
+func toRat(o interface{}) (*big.Rat, bool) {
+	switch v := o.(type) {
+	case json.Number:
+		return ratFromString(string(v))
+	case string:
+		return ratFromString(v)
+	case *big.Int:
+		if v == nil {
+			return nil, false
+		}
+		return new(big.Rat).SetInt(v), true
+	case *big.Float:
+		if v == nil || v.IsInf() {
+			return nil, false
+		}
+		r, _ := v.Rat(nil)
+		return r, true
+	}
+	rv := reflect.ValueOf(o)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	case reflect.Float32:
+		return ratFromString(strconv.FormatFloat(rv.Float(), 'g', -1, 32))
+	case reflect.Float64:
+		return ratFromString(strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+	}
+	return nil, false
+}
+
+func ratFromString(s string) (*big.Rat, bool) {
+	s = strings.TrimSpace(s)
+	// big.Rat also accepts fractions which are not JSON numbers
+	if s == "" || strings.ContainsAny(s, "/_") {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// cmpNumbers compares two numbers exactly, returns -1, 0 or +1
+func cmpNumbers(a, b interface{}) (int, bool) {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isIntKind(av.Kind()) && isIntKind(bv.Kind()):
+		x, y := av.Int(), bv.Int()
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case isFloatKind(av.Kind()) && isFloatKind(bv.Kind()) && av.Kind() == bv.Kind():
+		x, y := av.Float(), bv.Float()
+		if math.IsNaN(x) || math.IsNaN(y) {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := toRat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toRat(b)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// toIndex converts integral number to slice index
+func toIndex(o interface{}) (int, bool) {
+	switch v := o.(type) {
+	case int:
+		return v, true
+	case string:
+		return 0, false
+	}
+	if !isNumeric(o) {
+		return 0, false
+	}
+	r, ok := toRat(o)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	n := r.Num().Int64()
+	if int64(int(n)) != n {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// normalizeNumber converts json.Number and big numbers to int64, uint64 or float64
+// if it could be done without loss of precision
+func normalizeNumber(o interface{}) interface{} {
+	switch o.(type) {
+	case json.Number, *big.Int, *big.Float:
+	default:
+		return o
+	}
+	r, ok := toRat(o)
+	if !ok {
+		return o
+	}
+	if r.IsInt() {
+		if r.Num().IsInt64() {
+			return r.Num().Int64()
+		}
+		if r.Num().IsUint64() {
+			return r.Num().Uint64()
+		}
+		return o
+	}
+	if f, exact := r.Float64(); exact {
+		return f
+	}
+	return o
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
@@ -0,0 +1,145 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func useNumberData(t *testing.T, data string) interface{} {
+	dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+	dec.UseNumber()
+	var res interface{}
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func Test_JsonNumberFilter(t *testing.T) {
+	data := useNumberData(t, `{
+		"idx": 1,
+		"items": [
+			{"id": 12345678901234567890, "name": "a"},
+			{"id": 12345678901234567891, "name": "b"},
+			{"id": 8.95, "name": "c"}
+		]
+	}`)
+
+	tcases := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`$.items[?(@.id == 12345678901234567891)].name`, []interface{}{"b"}},
+		{`$.items[?(@.id < 12345678901234567891)].name`, []interface{}{"a", "c"}},
+		{`$.items[?(@.id >= 12345678901234567890)].name`, []interface{}{"a", "b"}},
+		{`$.items[?(@.id == 8.95)].name`, []interface{}{"c"}},
+		{`$.items[(@.idx)].name`, nil},
+		{`$.items[($.idx)].name`, "b"},
+	}
+	for _, tcase := range tcases {
+		res, err := JsonPathLookup(data, tcase.query)
+		if tcase.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tcase.query, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tcase.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tcase.expected) {
+			t.Errorf("%s: expected %v, got %v", tcase.query, tcase.expected, res)
+		}
+	}
+}
+
+func Test_BigNumbers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"n": huge, "name": "int"},
+			map[string]interface{}{"n": big.NewFloat(0.5), "name": "float"},
+			map[string]interface{}{"n": 3, "name": "plain"},
+		},
+		"idx": big.NewInt(2),
+	}
+
+	tcases := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`$.items[?(@.n > 123456789012345678901234567889)].name`, []interface{}{"int"}},
+		{`$.items[?(@.n == 123456789012345678901234567890)].name`, []interface{}{"int"}},
+		{`$.items[?(@.n < 1)].name`, []interface{}{"float"}},
+		{`$.items[?(@.n <= 3)].name`, []interface{}{"float", "plain"}},
+		{`$.items[($.idx)].name`, "plain"},
+	}
+	for _, tcase := range tcases {
+		res, err := JsonPathLookup(data, tcase.query)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tcase.expected) {
+			t.Errorf("%s: expected %v, got %v", tcase.query, tcase.expected, res)
+		}
+	}
+}
+
+func Test_cmpNumbers(t *testing.T) {
+	tcases := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{8.95, "8.95", 0},
+		{float32(8.95), 8.95, 0},
+		{json.Number("9007199254740993"), int64(9007199254740992), 1},
+		{uint64(1) << 63, int64(-1), 1},
+		{big.NewInt(-5), json.Number("-5.0"), 0},
+		{big.NewFloat(1.5), 2, -1},
+	}
+	for _, tcase := range tcases {
+		res, ok := cmpNumbers(tcase.a, tcase.b)
+		if !ok || res != tcase.expected {
+			t.Errorf("cmpNumbers(%v, %v): expected %d, got %d, %v", tcase.a, tcase.b, tcase.expected, res, ok)
+		}
+	}
+	if _, ok := cmpNumbers(json.Number("1/2"), 1); ok {
+		t.Error("fraction should not be a number")
+	}
+}
+
+func Test_JsonNumberConvert(t *testing.T) {
+	data := map[string]interface{}{"ints": []int64{1}, "floats": []float64{1.5}}
+	if err := Append(data, "$.ints", json.Number("9007199254740993")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(data, "$.floats", json.Number("8.95")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(data, "$.ints", json.Number("1.5")); err == nil {
+		t.Error("expected error for fractional number")
+	}
+	expected := map[string]interface{}{
+		"ints":   []int64{1, 9007199254740993},
+		"floats": []float64{1.5, 8.95},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("unexpected result: %v", data)
+	}
+}
+
+func Test_JsonNumberPatchTest(t *testing.T) {
+	doc := useNumberData(t, `{"id": 12345678901234567891}`)
+	_, err := ApplyPatch(doc, Patch{{Op: PatchTest, Path: "/id", Value: json.Number("12345678901234567890")}})
+	if err == nil {
+		t.Error("test operation should fail for different ids")
+	}
+	if _, err = ApplyPatch(doc, Patch{{Op: PatchTest, Path: "/id", Value: json.Number("12345678901234567891")}}); err != nil {
+		t.Error(err)
+	}
+}
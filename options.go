@@ -0,0 +1,349 @@
+package jsonpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultParallelThreshold is the minimal length of slice evaluated in parallel
+const DefaultParallelThreshold = 1024
+
+// ErrLimitExceeded is returned by LookupWith when evaluation exceeds one of LookupOptions limits
+var ErrLimitExceeded = errors.New("evaluation limit exceeded")
+
+// LookupOptions configures evaluation of LookupWith.
+// Zero value of a limit means no limit.
+type LookupOptions struct {
+	// Parallelism is the number of goroutines evaluating filters and keys of slice elements,
+	// slices are evaluated sequentially when Parallelism <= 1
+	Parallelism int
+	// ParallelThreshold is the minimal length of slice evaluated in parallel,
+	// DefaultParallelThreshold is used when it's 0
+	ParallelThreshold int
+
+	// MaxDepth limits how deep into the document path and its filters may descend
+	MaxDepth int
+	// MaxResults limits the number of nodes selected by any step of path
+	MaxResults int
+	// MaxSteps limits the number of evaluated selectors, including every visited slice element
+	MaxSteps int
+	// MaxRegexpLength limits the length of regular expressions in filters,
+	// CompileWith rejects longer regular expressions before compiling them
+	MaxRegexpLength int
+	// Context cancels evaluation, its error is returned
+	Context context.Context
+}
+
+// LookupOption is an option of LookupWith
+type LookupOption interface {
+	apply(o *LookupOptions)
+}
+
+// apply sets non-zero fields of o, so options set before or after it are kept
+func (o LookupOptions) apply(dst *LookupOptions) {
+	if o.Parallelism != 0 {
+		dst.Parallelism = o.Parallelism
+	}
+	if o.ParallelThreshold != 0 {
+		dst.ParallelThreshold = o.ParallelThreshold
+	}
+	if o.MaxDepth != 0 {
+		dst.MaxDepth = o.MaxDepth
+	}
+	if o.MaxResults != 0 {
+		dst.MaxResults = o.MaxResults
+	}
+	if o.MaxSteps != 0 {
+		dst.MaxSteps = o.MaxSteps
+	}
+	if o.MaxRegexpLength != 0 {
+		dst.MaxRegexpLength = o.MaxRegexpLength
+	}
+	if o.Context != nil {
+		dst.Context = o.Context
+	}
+}
+
+type optionFunc func(o *LookupOptions)
+
+func (f optionFunc) apply(o *LookupOptions) {
+	f(o)
+}
+
+// WithParallelism splits large slices between n goroutines for filter and wildcard steps.
+// Results keep the same order as with sequential evaluation.
+func WithParallelism(n int) LookupOption {
+	return optionFunc(func(o *LookupOptions) {
+		o.Parallelism = n
+	})
+}
+
+// WithContext cancels evaluation when ctx is done
+func WithContext(ctx context.Context) LookupOption {
+	return optionFunc(func(o *LookupOptions) {
+		o.Context = ctx
+	})
+}
+
+// CompileWith is Compile checking limits of options which depend on path only:
+// MaxDepth and MaxRegexpLength. Regular expressions are checked before they are compiled,
+// so untrusted paths should be compiled by CompileWith.
+//
+//	c, err := jsonpath.CompileWith(path, jsonpath.LookupOptions{MaxDepth: 16, MaxRegexpLength: 64})
+func CompileWith(jpath string, opts ...LookupOption) (*Compiled, error) {
+	e := &evaluation{}
+	for _, opt := range opts {
+		opt.apply(&e.LookupOptions)
+	}
+	c, err := compile(jpath, e.MaxRegexpLength)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkPath(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LookupWith is Lookup configured by options, options are applied in order
+// and LookupOptions values set only their non-zero fields.
+// Evaluation stopped by limits returns error wrapping ErrLimitExceeded,
+// evaluation stopped by context returns the context error.
+//
+//	res, err := c.LookupWith(obj, jsonpath.LookupOptions{MaxResults: 1000, MaxSteps: 100000}, jsonpath.WithContext(ctx))
+//	if errors.Is(err, jsonpath.ErrLimitExceeded) {
+//	}
+func (c *Compiled) LookupWith(rootObj interface{}, opts ...LookupOption) (interface{}, error) {
+	e := &evaluation{}
+	for _, opt := range opts {
+		opt.apply(&e.LookupOptions)
+	}
+	if err := e.checkPath(c); err != nil {
+		return nil, err
+	}
+	if err := e.checkContext(); err != nil {
+		return nil, err
+	}
+	return c.lookup(rootObj, e)
+}
+
+// evaluation is a state of a single LookupWith call, nil evaluation has no options
+type evaluation struct {
+	LookupOptions
+	steps int64
+}
+
+// contextCheckInterval is the number of steps between context checks
+const contextCheckInterval = 256
+
+// checkPath validates limits which depend on path only
+func (e *evaluation) checkPath(c *Compiled) error {
+	if e.MaxDepth > 0 {
+		if depth := c.depth(); depth > e.MaxDepth {
+			return fmt.Errorf("%w: path depth %d, max depth %d", ErrLimitExceeded, depth, e.MaxDepth)
+		}
+	}
+	if e.MaxRegexpLength > 0 {
+		for _, s := range c.steps {
+			if s.filter == nil || s.filter.pat == nil {
+				continue
+			}
+			if length := len(s.filter.pat.String()); length > e.MaxRegexpLength {
+				return regexpLengthError(length, e.MaxRegexpLength)
+			}
+		}
+	}
+	return nil
+}
+
+func regexpLengthError(length, max int) error {
+	return fmt.Errorf("%w: regexp length %d, max length %d", ErrLimitExceeded, length, max)
+}
+
+// tick counts n evaluation steps and checks MaxSteps and context
+func (e *evaluation) tick(n int) error {
+	if e == nil {
+		return nil
+	}
+	steps := atomic.AddInt64(&e.steps, int64(n))
+	if e.MaxSteps > 0 && steps > int64(e.MaxSteps) {
+		return fmt.Errorf("%w: max steps %d", ErrLimitExceeded, e.MaxSteps)
+	}
+	if steps/contextCheckInterval != (steps-int64(n))/contextCheckInterval {
+		return e.checkContext()
+	}
+	return nil
+}
+
+func (e *evaluation) checkContext() error {
+	if e.Context == nil {
+		return nil
+	}
+	return e.Context.Err()
+}
+
+// checkResults checks the number of nodes selected by a step
+func (e *evaluation) checkResults(obj interface{}) error {
+	if e == nil || e.MaxResults <= 0 {
+		return nil
+	}
+	if length, ok := sliceLen(obj); ok && length > e.MaxResults {
+		return fmt.Errorf("%w: %d results, max results %d", ErrLimitExceeded, length, e.MaxResults)
+	}
+	return nil
+}
+
+// workers returns number of goroutines to evaluate slice of given length, 1 for sequential evaluation
+func (e *evaluation) workers(length int) int {
+	if e.Parallelism <= 1 {
+		return 1
+	}
+	threshold := e.ParallelThreshold
+	if threshold <= 0 {
+		threshold = DefaultParallelThreshold
+	}
+	if length < threshold {
+		return 1
+	}
+	return e.Parallelism
+}
+
+// getKey is get_key counting every visited slice element and evaluating large slices in parallel
+func (e *evaluation) getKey(obj interface{}, key string) (interface{}, error) {
+	length, ok := sliceLen(obj)
+	if !ok || e == nil {
+		return get_key(obj, key)
+	}
+	values := make([]interface{}, length)
+	found := make([]bool, length)
+	err := parallelEach(length, e.workers(length), func(i int) error {
+		if err := e.tick(1); err != nil {
+			return err
+		}
+		v, err := e.getKey(sliceElem(obj, i), key)
+		if isAbort(err) {
+			return err
+		}
+		// elements without key are skipped
+		values[i], found[i] = v, err == nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for i, v := range values {
+		if found[i] {
+			res = append(res, v)
+		}
+	}
+	if len(res) == 0 {
+		return nil, NotExist{key: key}
+	}
+	return res, nil
+}
+
+// filtered is get_filtered counting every visited slice element and evaluating large slices in parallel
+func (e *evaluation) filtered(obj, root interface{}, f *filter) ([]interface{}, error) {
+	length, ok := sliceLen(obj)
+	if e == nil || !ok {
+		if objVal := reflect.ValueOf(obj); objVal.Kind() == reflect.Map {
+			if err := e.tick(objVal.Len()); err != nil {
+				return nil, err
+			}
+		}
+		return get_filtered(obj, root, f)
+	}
+	matched := make([]bool, length)
+	err := parallelEach(length, e.workers(length), func(i int) error {
+		if err := e.tick(1); err != nil {
+			return err
+		}
+		ok, err := f.match(sliceElem(obj, i), root)
+		matched[i] = ok
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for i, ok := range matched {
+		if ok {
+			res = append(res, sliceElem(obj, i))
+		}
+	}
+	return res, nil
+}
+
+// isAbort reports whether err stops evaluation instead of skipping the element
+func isAbort(err error) bool {
+	return errors.Is(err, ErrLimitExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// depth returns how deep into the document path and its filters descend
+func (c *Compiled) depth() int {
+	res := 0
+	for i, s := range c.selectors() {
+		d := i + 1
+		if s.filter != nil {
+			sub := len(s.filter.left.steps)
+			if s.filter.right != nil && len(s.filter.right.steps) > sub {
+				sub = len(s.filter.right.steps)
+			}
+			d += sub
+		}
+		if s.expr != nil {
+			d += len(s.expr.steps)
+		}
+		if d > res {
+			res = d
+		}
+	}
+	return res
+}
+
+// parallelEach calls fn for every index below n, indexes are split to contiguous chunks
+// between workers. Returns the error of the smallest index like sequential loop would do.
+func parallelEach(n, workers int, fn func(i int) error) error {
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if workers > n {
+		workers = n
+	}
+	errs := make([]error, workers)
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := w*chunk, (w+1)*chunk
+		if to > n {
+			to = n
+		}
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				if err := fn(i); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w, from, to)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
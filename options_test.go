@@ -0,0 +1,213 @@
+package jsonpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func parallelTestData(n int) interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		records[i] = map[string]interface{}{
+			"id":    float64(i),
+			"name":  fmt.Sprintf("record-%d", i),
+			"level": []interface{}{"info", "warn", "error"}[i%3],
+		}
+	}
+	return map[string]interface{}{"records": records}
+}
+
+func Test_LookupWithParallelism(t *testing.T) {
+	data := parallelTestData(5000)
+	for _, path := range []string{
+		"$.records[?(@.level == 'error')].name",
+		"$.records[?(@.id >= 4990)]",
+		"$.records[*].id",
+		"$.records[100:3000].name",
+		"$.records[?(@.name =~ /-1\\d*$/)].id",
+	} {
+		c := MustCompile(path)
+		expected, err := c.Lookup(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range [][]LookupOption{
+			{WithParallelism(4)},
+			{WithParallelism(7), LookupOptions{Parallelism: 3, ParallelThreshold: 10}},
+			{LookupOptions{ParallelThreshold: 10}, WithParallelism(16)},
+		} {
+			res, err := c.LookupWith(data, opts...)
+			if err != nil {
+				t.Errorf("%s: %v", path, err)
+				continue
+			}
+			if !reflect.DeepEqual(res, expected) {
+				t.Errorf("%s: results differ from sequential lookup", path)
+			}
+		}
+	}
+}
+
+func Test_LookupWithParallelismErrors(t *testing.T) {
+	data := parallelTestData(3000)
+	records := data.(map[string]interface{})["records"].([]interface{})
+	records[2900].(map[string]interface{})["name"] = 1
+	records[10].(map[string]interface{})["name"] = 2
+
+	c := MustCompile("$.records[?(@.name =~ /record/)]")
+	_, expected := c.Lookup(data)
+	_, err := c.LookupWith(data, WithParallelism(4))
+	if err == nil || err.Error() != expected.Error() {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+
+	_, expected = MustCompile("$.records[*].missing").Lookup(data)
+	_, err = MustCompile("$.records[*].missing").LookupWith(data, WithParallelism(4))
+	if _, ok := err.(NotExist); !ok || err.Error() != expected.Error() {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func Test_LookupWithLimits(t *testing.T) {
+	data := parallelTestData(5000)
+	tcases := []struct {
+		path string
+		opts LookupOptions
+		err  bool
+	}{
+		{"$.records[0].name", LookupOptions{MaxDepth: 3}, false},
+		{"$.records[0].name", LookupOptions{MaxDepth: 2}, true},
+		{"$.records[?(@.level == 'error')]", LookupOptions{MaxDepth: 3}, false},
+		{"$.records[?(@.name.first == 'a')]", LookupOptions{MaxDepth: 3}, true},
+		{"$.records[?(@.level == 'error')]", LookupOptions{MaxResults: 2000}, false},
+		{"$.records[?(@.level == 'error')]", LookupOptions{MaxResults: 1000}, true},
+		{"$.records[*].id", LookupOptions{MaxResults: 4999}, true},
+		{"$.records[0:9].id", LookupOptions{MaxResults: 10}, false},
+		{"$.records[*].id", LookupOptions{MaxSteps: 6000}, false},
+		{"$.records[*].id", LookupOptions{MaxSteps: 4000}, true},
+		{"$.records[?(@.id > 10)]", LookupOptions{MaxSteps: 4000, Parallelism: 4}, true},
+		{"$.records[?(@.name =~ /-1\\d*$/)]", LookupOptions{MaxRegexpLength: 6}, false},
+		{"$.records[?(@.name =~ /-1\\d*$/)]", LookupOptions{MaxRegexpLength: 5}, true},
+	}
+	for _, tcase := range tcases {
+		c := MustCompile(tcase.path)
+		res, err := c.LookupWith(data, tcase.opts)
+		if !tcase.err {
+			expected, _ := c.Lookup(data)
+			if err != nil || !reflect.DeepEqual(res, expected) {
+				t.Errorf("%s %+v: unexpected error %v", tcase.path, tcase.opts, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s %+v: expected limit error, got %v", tcase.path, tcase.opts, err)
+		}
+	}
+}
+
+func Test_LookupWithTypedResults(t *testing.T) {
+	data := map[string]interface{}{"ints": make([]int, 100)}
+	c := MustCompile("$.ints[0:49]")
+	if _, err := c.LookupWith(data, LookupOptions{MaxResults: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.LookupWith(data, LookupOptions{MaxResults: 10}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected limit error, got %v", err)
+	}
+}
+
+func Test_CompileWith(t *testing.T) {
+	opts := LookupOptions{MaxDepth: 3, MaxRegexpLength: 5}
+	if _, err := CompileWith("$.records[?(@.name =~ /a.*b/)]", opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"$.records[?(@.name =~ /-1\\d*$/)]",
+		// invalid regexp is rejected by length before it is compiled
+		"$.records[?(@.name =~ /(((((((/)]",
+		"$.records[?(@.name.first == 'a')]",
+	} {
+		if _, err := CompileWith(path, opts); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: expected limit error, got %v", path, err)
+		}
+	}
+	if _, err := CompileWith("$.records[?(@.name =~ /(/)]", opts); err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected regexp error, got %v", err)
+	}
+}
+
+func Test_LookupWithContext(t *testing.T) {
+	data := parallelTestData(5000)
+	c := MustCompile("$.records[?(@.level == 'error')].name")
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := c.LookupWith(data, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for _, opts := range [][]LookupOption{
+		{WithContext(ctx)},
+		{WithContext(ctx), WithParallelism(4)},
+		{LookupOptions{MaxResults: 10000}, WithContext(ctx)},
+		{WithContext(ctx), LookupOptions{MaxResults: 10000}},
+	} {
+		if _, err := c.LookupWith(data, opts...); err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	}
+}
+
+func Test_LookupOptionsOrder(t *testing.T) {
+	ctx := context.Background()
+	expected := LookupOptions{Parallelism: 4, MaxSteps: 10, MaxResults: 5, Context: ctx}
+	for _, opts := range [][]LookupOption{
+		{LookupOptions{MaxSteps: 10, MaxResults: 5}, WithParallelism(4), WithContext(ctx)},
+		{WithParallelism(4), WithContext(ctx), LookupOptions{MaxSteps: 10, MaxResults: 5}},
+		{WithContext(ctx), LookupOptions{MaxSteps: 10, Parallelism: 2}, LookupOptions{MaxResults: 5}, WithParallelism(4)},
+	} {
+		var res LookupOptions
+		for _, opt := range opts {
+			opt.apply(&res)
+		}
+		if res != expected {
+			t.Errorf("expected %+v, got %+v", expected, res)
+		}
+	}
+}
+
+func Test_parallelEach(t *testing.T) {
+	for _, n := range []int{1, 5, 10, 11} {
+		seen := make([]int, n)
+		parallelEach(n, 4, func(i int) error {
+			seen[i]++
+			return nil
+		})
+		for i, count := range seen {
+			if count != 1 {
+				t.Errorf("n=%d: index %d visited %d times", n, i, count)
+			}
+		}
+	}
+}
+
+func BenchmarkLookupSequential(b *testing.B) {
+	data := parallelTestData(100000)
+	c := MustCompile("$.records[?(@.level == 'error')].name")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Lookup(data)
+	}
+}
+
+func BenchmarkLookupParallel(b *testing.B) {
+	data := parallelTestData(100000)
+	c := MustCompile("$.records[?(@.level == 'error')].name")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.LookupWith(data, WithParallelism(8))
+	}
+}
@@ -0,0 +1,565 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSON Patch operations, see RFC 6902
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchCopy    = "copy"
+	PatchTest    = "test"
+)
+
+// ErrTestFailed is returned when "test" patch operation doesn't match the document
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is a single JSON Patch operation
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// Patch is a RFC 6902 JSON Patch document
+type Patch []Operation
+
+// PatchError is returned by ApplyPatch and contains index of the failed operation
+type PatchError struct {
+	Index int
+	Op    Operation
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+// DecodePatch parse JSON Patch document
+func DecodePatch(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// UnmarshalJSON validates presence of the members required by the operation
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	str := func(name string) (string, error) {
+		v, ok := raw[name]
+		if !ok {
+			return "", fmt.Errorf("patch operation missing %q member", name)
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return "", fmt.Errorf("patch operation member %q should be a string", name)
+		}
+		return s, nil
+	}
+	var op Operation
+	var err error
+	if op.Op, err = str("op"); err != nil {
+		return err
+	}
+	if op.Path, err = str("path"); err != nil {
+		return err
+	}
+	switch op.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		v, ok := raw["value"]
+		if !ok {
+			return fmt.Errorf("patch operation %q missing \"value\" member", op.Op)
+		}
+		if err := json.Unmarshal(v, &op.Value); err != nil {
+			return err
+		}
+	case PatchMove, PatchCopy:
+		if op.From, err = str("from"); err != nil {
+			return err
+		}
+	case PatchRemove:
+	default:
+		return fmt.Errorf("unknown patch operation %q", op.Op)
+	}
+	*o = op
+	return nil
+}
+
+// MarshalJSON emits only members meaningful for the operation
+func (o Operation) MarshalJSON() ([]byte, error) {
+	raw := map[string]interface{}{
+		"op":   o.Op,
+		"path": o.Path,
+	}
+	switch o.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		raw["value"] = o.Value
+	case PatchMove, PatchCopy:
+		raw["from"] = o.From
+	}
+	return json.Marshal(raw)
+}
+
+// ApplyPatch applies JSON Patch to doc and returns patched document.
+// Containers of doc are modified in place, but slices may be reallocated and
+// the root may be replaced, so the returned value should be used afterwards.
+// Patch is atomic: if any operation fails all previous changes are rolled back
+// and *PatchError is returned.
+func ApplyPatch(doc interface{}, patch Patch) (interface{}, error) {
+	doc = followPtr(doc)
+	res := doc
+	var undo undoLog
+	for i, op := range patch {
+		var err error
+		res, err = applyOperation(res, op, &undo)
+		if err != nil {
+			undo.rollback()
+			return doc, &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+	return res, nil
+}
+
+func applyOperation(doc interface{}, op Operation, undo *undoLog) (interface{}, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case PatchAdd:
+		return patchAdd(doc, tokens, op.Value, undo)
+	case PatchRemove:
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("could not remove the whole document")
+		}
+		return updateAtPointer(doc, tokens, undo, func(parent interface{}, key string) (interface{}, error) {
+			return removeChild(parent, key, undo)
+		})
+	case PatchReplace:
+		if len(tokens) == 0 {
+			return op.Value, nil
+		}
+		return updateAtPointer(doc, tokens, undo, func(parent interface{}, key string) (interface{}, error) {
+			if _, err := pointerChild(parent, key); err != nil {
+				return nil, err
+			}
+			return setChild(parent, key, op.Value, undo)
+		})
+	case PatchMove:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.From == op.Path {
+			return doc, nil
+		}
+		if len(from) == 0 || strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("could not move %q into its own child", op.From)
+		}
+		value, err := resolvePointerTokens(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = updateAtPointer(doc, from, undo, func(parent interface{}, key string) (interface{}, error) {
+			return removeChild(parent, key, undo)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, tokens, value, undo)
+	case PatchCopy:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := resolvePointerTokens(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, tokens, deepCopy(value), undo)
+	case PatchTest:
+		value, err := resolvePointerTokens(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !valuesEqual(value, op.Value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown patch operation %q", op.Op)
+	}
+}
+
+func patchAdd(doc interface{}, tokens []string, value interface{}, undo *undoLog) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updateAtPointer(doc, tokens, undo, func(parent interface{}, key string) (interface{}, error) {
+		return insertChild(parent, key, value, undo)
+	})
+}
+
+// updateAtPointer calls fn with the parent container of the node referenced by tokens
+// and stores containers returned by fn back to their parents
+func updateAtPointer(node interface{}, tokens []string, undo *undoLog, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	node = followPtr(node)
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	child, err := pointerChild(node, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	newChild, err := updateAtPointer(child, tokens[1:], undo, fn)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.ValueOf(child).Kind() != reflect.Slice {
+		// maps are modified in place
+		return node, nil
+	}
+	return setChild(node, tokens[0], newChild, undo)
+}
+
+func resolvePointerTokens(doc interface{}, tokens []string) (interface{}, error) {
+	var err error
+	for _, token := range tokens {
+		doc, err = pointerChild(doc, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func pointerChild(obj interface{}, token string) (interface{}, error) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		return get_key(v, token)
+	case []interface{}:
+		idx, err := parseArrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		return v[idx], nil
+	}
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
+	}
+	obj = followPtr(obj)
+	objVal := reflect.ValueOf(obj)
+	switch objVal.Kind() {
+	case reflect.Map:
+		return get_key(obj, token)
+	case reflect.Slice:
+		idx, err := parseArrayIndex(token, objVal.Len(), false)
+		if err != nil {
+			return nil, err
+		}
+		return objVal.Index(idx).Interface(), nil
+	default:
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+}
+
+// setChild replaces existing slice element or sets map key
+func setChild(parent interface{}, token string, value interface{}, undo *undoLog) (interface{}, error) {
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		undo.setKey(v, token, value)
+		return parent, nil
+	case []interface{}:
+		idx, err := parseArrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		undo.setElem(v, idx, value)
+		return parent, nil
+	}
+	parentVal := reflect.ValueOf(parent)
+	switch parentVal.Kind() {
+	case reflect.Map:
+		keyVal, err := convertValue(parentVal.Type().Key(), token)
+		if err != nil {
+			return nil, err
+		}
+		newVal, err := convertValue(parentVal.Type().Elem(), value)
+		if err != nil {
+			return nil, err
+		}
+		undo.setMapIndex(parentVal, keyVal, newVal)
+		return parent, nil
+	case reflect.Slice:
+		idx, err := parseArrayIndex(token, parentVal.Len(), false)
+		if err != nil {
+			return nil, err
+		}
+		newVal, err := convertValue(parentVal.Type().Elem(), value)
+		if err != nil {
+			return nil, err
+		}
+		undo.setIndex(parentVal, idx, newVal)
+		return parent, nil
+	default:
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+}
+
+// insertChild sets map key or inserts new element to the slice
+func insertChild(parent interface{}, token string, value interface{}, undo *undoLog) (interface{}, error) {
+	if arr, ok := parent.([]interface{}); ok {
+		idx, err := parseArrayIndex(token, len(arr), true)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, len(arr)+1)
+		res = append(res, arr[:idx]...)
+		res = append(res, value)
+		return append(res, arr[idx:]...), nil
+	}
+	parentVal := reflect.ValueOf(parent)
+	if parentVal.Kind() != reflect.Slice {
+		return setChild(parent, token, value, undo)
+	}
+	idx, err := parseArrayIndex(token, parentVal.Len(), true)
+	if err != nil {
+		return nil, err
+	}
+	newVal, err := convertValue(parentVal.Type().Elem(), value)
+	if err != nil {
+		return nil, err
+	}
+	// new backing array keeps the original slice intact for rollback
+	res := reflect.MakeSlice(parentVal.Type(), 0, parentVal.Len()+1)
+	res = reflect.AppendSlice(res, parentVal.Slice(0, idx))
+	res = reflect.Append(res, newVal)
+	res = reflect.AppendSlice(res, parentVal.Slice(idx, parentVal.Len()))
+	return res.Interface(), nil
+}
+
+// removeChild deletes existing map key or slice element
+func removeChild(parent interface{}, token string, undo *undoLog) (interface{}, error) {
+	if _, err := pointerChild(parent, token); err != nil {
+		return nil, err
+	}
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		undo.deleteKey(v, token)
+		return parent, nil
+	case []interface{}:
+		idx, _ := parseArrayIndex(token, len(v), false)
+		res := make([]interface{}, 0, len(v)-1)
+		res = append(res, v[:idx]...)
+		return append(res, v[idx+1:]...), nil
+	}
+	parentVal := reflect.ValueOf(parent)
+	switch parentVal.Kind() {
+	case reflect.Map:
+		keyVal, err := convertValue(parentVal.Type().Key(), token)
+		if err != nil {
+			return nil, err
+		}
+		undo.setMapIndex(parentVal, keyVal, reflect.Value{})
+		return parent, nil
+	default:
+		idx, _ := parseArrayIndex(token, parentVal.Len(), false)
+		res := reflect.MakeSlice(parentVal.Type(), 0, parentVal.Len()-1)
+		res = reflect.AppendSlice(res, parentVal.Slice(0, idx))
+		res = reflect.AppendSlice(res, parentVal.Slice(idx+1, parentVal.Len()))
+		return res.Interface(), nil
+	}
+}
+
+// undoLog collects the actions required to revert in place modifications
+type undoLog []func()
+
+func (u *undoLog) setMapIndex(m, key, value reflect.Value) {
+	if u != nil {
+		old := m.MapIndex(key)
+		*u = append(*u, func() {
+			m.SetMapIndex(key, old)
+		})
+	}
+	m.SetMapIndex(key, value)
+}
+
+func (u *undoLog) setIndex(s reflect.Value, idx int, value reflect.Value) {
+	elem := s.Index(idx)
+	if u != nil {
+		old := reflect.New(elem.Type()).Elem()
+		old.Set(elem)
+		*u = append(*u, func() {
+			elem.Set(old)
+		})
+	}
+	elem.Set(value)
+}
+
+// setKey is setMapIndex for map[string]interface{}
+func (u *undoLog) setKey(m map[string]interface{}, key string, value interface{}) {
+	u.saveKey(m, key)
+	m[key] = value
+}
+
+// deleteKey removes key of map[string]interface{}
+func (u *undoLog) deleteKey(m map[string]interface{}, key string) {
+	u.saveKey(m, key)
+	delete(m, key)
+}
+
+func (u *undoLog) saveKey(m map[string]interface{}, key string) {
+	if u == nil {
+		return
+	}
+	old, exists := m[key]
+	*u = append(*u, func() {
+		if exists {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// setElem is setIndex for []interface{}
+func (u *undoLog) setElem(s []interface{}, idx int, value interface{}) {
+	if u != nil {
+		old := s[idx]
+		*u = append(*u, func() {
+			s[idx] = old
+		})
+	}
+	s[idx] = value
+}
+
+func (u *undoLog) rollback() {
+	for i := len(*u) - 1; i >= 0; i-- {
+		(*u)[i]()
+	}
+	*u = nil
+}
+
+// deepCopy returns copy of obj with all maps and slices duplicated
+func deepCopy(obj interface{}) interface{} {
+	if obj == nil {
+		return nil
+	}
+	return deepCopyValue(reflect.ValueOf(obj)).Interface()
+}
+
+func deepCopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type()).Elem()
+		res.Set(deepCopyValue(v.Elem()))
+		return res
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type().Elem())
+		res.Elem().Set(deepCopyValue(v.Elem()))
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			res.SetMapIndex(k, deepCopyValue(v.MapIndex(k)))
+		}
+		return res
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// valuesEqual compares two JSON values, numbers are equal regardless of their Go types
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	a = followPtr(a)
+	b = followPtr(b)
+	if isNumeric(a) {
+		if !isNumeric(b) {
+			return false
+		}
+		res, ok := cmpNumbers(a, b)
+		return ok && res == 0
+	}
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	switch av.Kind() {
+	case reflect.Map:
+		if bv.Kind() != reflect.Map || av.Len() != bv.Len() {
+			return false
+		}
+		for _, k := range av.MapKeys() {
+			bk, err := convertValue(bv.Type().Key(), k.Interface())
+			if err != nil {
+				return false
+			}
+			other := bv.MapIndex(bk)
+			if !other.IsValid() || !valuesEqual(av.MapIndex(k).Interface(), other.Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if bv.Kind() != reflect.Slice || av.Len() != bv.Len() {
+			return false
+		}
+		for i := 0; i < av.Len(); i++ {
+			if !valuesEqual(av.Index(i).Interface(), bv.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return bv.Kind() == reflect.String && av.String() == bv.String()
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// toFloat converts numeric types to float64
+func toFloat(o interface{}) (float64, bool) {
+	v := reflect.ValueOf(o)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
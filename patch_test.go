@@ -0,0 +1,181 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_parsePointer(t *testing.T) {
+	tcases := []struct {
+		ptr    string
+		tokens []string
+		err    bool
+	}{
+		{"", []string{}, false},
+		{"/", []string{""}, false},
+		{"/foo/0", []string{"foo", "0"}, false},
+		{"/a~1b/m~0n", []string{"a/b", "m~n"}, false},
+		{"/~01", []string{"~1"}, false},
+		{"foo", nil, true},
+		{"/a~2", nil, true},
+		{"/a~", nil, true},
+	}
+	for _, tcase := range tcases {
+		tokens, err := parsePointer(tcase.ptr)
+		if tcase.err {
+			if err == nil {
+				t.Errorf("%q: expected error", tcase.ptr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(tokens, tcase.tokens) {
+			t.Errorf("%q: got %q, %v; expected %q", tcase.ptr, tokens, err, tcase.tokens)
+		}
+	}
+}
+
+var patch_cases = []struct {
+	doc   string
+	patch string
+	exp   string
+}{
+	{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+	{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+	{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc"]}]`, `{"foo": ["bar", ["abc"]]}`},
+	{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+	{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+	{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+	{`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+	{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+	{`{"foo": {"bar": [1]}}`, `[{"op": "copy", "from": "/foo/bar", "path": "/baz"}, {"op": "add", "path": "/baz/-", "value": 2}]`, `{"foo": {"bar": [1]}, "baz": [1, 2]}`},
+	{`{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`, `{"baz": "qux", "foo": ["a", 2, "c"]}`},
+	{`{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`, `{"~1": 10}`},
+	{`{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`},
+	{`{"foo": "bar"}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+	{`[1, 2]`, `[{"op": "add", "path": "/0", "value": 0}]`, `[0, 1, 2]`},
+	{`{"foo": null}`, `[{"op": "test", "path": "/foo", "value": null}, {"op": "add", "path": "/bar", "value": null}]`, `{"foo": null, "bar": null}`},
+}
+
+func Test_ApplyPatch(t *testing.T) {
+	for idx, tcase := range patch_cases {
+		var doc, exp interface{}
+		if err := json.Unmarshal([]byte(tcase.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tcase.exp), &exp); err != nil {
+			t.Fatal(err)
+		}
+		p, err := DecodePatch([]byte(tcase.patch))
+		if err != nil {
+			t.Errorf("[%d] decode: %v", idx, err)
+			continue
+		}
+		res, err := ApplyPatch(doc, p)
+		if err != nil {
+			t.Errorf("[%d] apply: %v", idx, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, exp) {
+			t.Errorf("[%d] got: %v, expected: %v", idx, res, exp)
+		}
+	}
+}
+
+func Test_ApplyPatchRollback(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"a": {"b": [1, 2, 3]}, "c": "d"}`), &doc)
+	orig := deepCopy(doc)
+
+	p, err := DecodePatch([]byte(`[
+		{"op": "replace", "path": "/c", "value": "e"},
+		{"op": "remove", "path": "/a/b/0"},
+		{"op": "add", "path": "/a/x", "value": 1},
+		{"op": "replace", "path": "/a/b/0", "value": 42},
+		{"op": "test", "path": "/c", "value": "d"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ApplyPatch(doc, p)
+	perr, ok := err.(*PatchError)
+	if !ok {
+		t.Fatalf("expected *PatchError, got: %v", err)
+	}
+	if perr.Index != 4 || perr.Err != ErrTestFailed {
+		t.Errorf("unexpected error: %v", perr)
+	}
+	if !reflect.DeepEqual(doc, orig) || !reflect.DeepEqual(res, orig) {
+		t.Errorf("document was not rolled back: %v", doc)
+	}
+}
+
+func Test_ApplyPatchErrors(t *testing.T) {
+	tcases := []string{
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "add", "path": "/a/5", "value": 1}]`,
+		`[{"op": "add", "path": "/a/01", "value": 1}]`,
+		`[{"op": "replace", "path": "/a/-", "value": 1}]`,
+		`[{"op": "move", "from": "/a", "path": "/a/0"}]`,
+		`[{"op": "add", "path": "/x/y", "value": 1}]`,
+	}
+	for _, tcase := range tcases {
+		doc := map[string]interface{}{"a": []interface{}{1.0}}
+		p, err := DecodePatch([]byte(tcase))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ApplyPatch(doc, p); err == nil {
+			t.Errorf("%s: expected error", tcase)
+		}
+	}
+
+	for _, tcase := range []string{
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "move", "path": "/a"}]`,
+		`[{"op": "unknown", "path": "/a"}]`,
+		`[{"path": "/a"}]`,
+	} {
+		if _, err := DecodePatch([]byte(tcase)); err == nil {
+			t.Errorf("%s: expected decode error", tcase)
+		}
+	}
+}
+
+func Test_ApplyPatchTypedContainers(t *testing.T) {
+	data := map[string]interface{}{
+		"ints": []int{1, 2},
+		"strs": map[string]string{"a": "b"},
+	}
+	p := Patch{
+		{Op: PatchAdd, Path: "/ints/-", Value: 3.0},
+		{Op: PatchReplace, Path: "/strs/a", Value: "c"},
+	}
+	res, err := ApplyPatch(&data, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := res.(map[string]interface{})
+	if !reflect.DeepEqual(m["ints"], []int{1, 2, 3}) || m["strs"].(map[string]string)["a"] != "c" {
+		t.Errorf("unexpected result: %v", m)
+	}
+
+	if _, err := ApplyPatch(&data, Patch{{Op: PatchAdd, Path: "/ints/-", Value: "x"}}); err == nil {
+		t.Error("expected type error")
+	}
+}
+
+func Test_OperationMarshal(t *testing.T) {
+	p := Patch{
+		{Op: PatchAdd, Path: "/a", Value: nil},
+		{Op: PatchMove, Path: "/b", From: "/a"},
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `[{"op":"add","path":"/a","value":null},{"from":"/a","op":"move","path":"/b"}]`
+	if string(data) != exp {
+		t.Errorf("got: %s", data)
+	}
+}
@@ -0,0 +1,140 @@
+package jsonpath
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parsePointer split RFC 6901 JSON Pointer to unescaped reference tokens.
+// Empty pointer references the whole document and returns no tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return []string{}, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q: should start with '/'", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid json pointer %q: bad escape sequence in %q", ptr, token)
+			}
+		}
+		tokens[i] = unescapePointerToken(token)
+	}
+	return tokens, nil
+}
+
+func unescapePointerToken(token string) string {
+	token = strings.Replace(token, "~1", "/", -1)
+	return strings.Replace(token, "~0", "~", -1)
+}
+
+func escapePointerToken(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	return strings.Replace(token, "/", "~1", -1)
+}
+
+// parseArrayIndex convert reference token to array index.
+// When allowEnd is set the "-" token references the position after the last element
+func parseArrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("index \"-\" is not allowed here")
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid array index %q", token)
+		}
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := length
+	if allowEnd {
+		limit++
+	}
+	if idx >= limit {
+		return 0, IndexOutOfRange{Len: length, Index: idx}
+	}
+	return idx, nil
+}
+
+// pointer returns JSON Pointer of the location
+func (l location) pointer() string {
+	var b strings.Builder
+	for _, token := range l.tokens() {
+		b.WriteString("/")
+		b.WriteString(escapePointerToken(token))
+	}
+	return b.String()
+}
+
+// ToPointer converts singular path, which contains only keys and non-negative indexes,
+// to RFC 6901 JSON Pointer:
+//
+//	$.store.book[0]['a/b'] -> /store/book/0/a~1b
+func ToPointer(c *Compiled) (string, error) {
+	steps := c.selectors()
+	loc := make(location, len(steps))
+	for i, s := range steps {
+		switch {
+		case s.op == KeyOp:
+			loc[i] = s.key
+		case s.op == IndexOp && len(s.args.([]int)) == 1 && s.args.([]int)[0] >= 0:
+			loc[i] = s.args.([]int)[0]
+		default:
+			return "", fmt.Errorf("path %s is not singular, only keys and non-negative indexes may be converted to json pointer", c.path)
+		}
+	}
+	return loc.pointer(), nil
+}
+
+// FromPointer converts RFC 6901 JSON Pointer to path.
+// Tokens which are array indexes are converted to indexes, so they don't match keys of maps,
+// use ResolvePointer to resolve such pointers against the document.
+//
+//	/store/book/0/a~1b -> $['store']['book'][0]['a/b']
+func FromPointer(ptr string) (*Compiled, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	loc := make(location, len(tokens))
+	for i, token := range tokens {
+		if idx, err := parseArrayIndex(token, math.MaxInt32, false); err == nil {
+			loc[i] = idx
+		} else {
+			loc[i] = token
+		}
+	}
+	c, err := Compile(loc.String())
+	if err != nil {
+		return nil, fmt.Errorf("json pointer %q could not be converted to path: %v", ptr, err)
+	}
+	// path should be parsed back to the same pointer
+	if back, err := ToPointer(c); err != nil || back != loc.pointer() {
+		return nil, fmt.Errorf("json pointer %q could not be converted to path", ptr)
+	}
+	return c, nil
+}
+
+// ResolvePointer returns value referenced by RFC 6901 JSON Pointer.
+// Maps are traversed by keys like Lookup does and slices by indexes,
+// so numeric tokens reference both keys of maps and elements of slices.
+func ResolvePointer(doc interface{}, ptr string) (interface{}, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return resolvePointerTokens(followPtr(doc), tokens)
+}
@@ -0,0 +1,97 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_ToPointer(t *testing.T) {
+	tcases := []struct {
+		path, ptr string
+	}{
+		{"$", ""},
+		{"$.store.book[0].title", "/store/book/0/title"},
+		{"$['a/b']['c~d']", "/a~1b/c~0d"},
+		{"$['a.b'].c[12]", "/a.b/c/12"},
+		{"$.a[1:2]", ""},
+		{"$.a[*]", ""},
+		{"$.a[0,1]", ""},
+		{"$.a[-1]", ""},
+		{"$.a[?(@.b == 1)]", ""},
+		{"$.a[($.i)]", ""},
+	}
+	for _, tcase := range tcases {
+		ptr, err := ToPointer(MustCompile(tcase.path))
+		if tcase.ptr == "" && tcase.path != "$" {
+			if err == nil {
+				t.Errorf("%s: expected error, got %q", tcase.path, ptr)
+			}
+			continue
+		}
+		if err != nil || ptr != tcase.ptr {
+			t.Errorf("%s: expected %q, got %q, %v", tcase.path, tcase.ptr, ptr, err)
+		}
+	}
+}
+
+func Test_FromPointer(t *testing.T) {
+	tcases := []struct {
+		ptr, path string
+	}{
+		{"", "$"},
+		{"/store/book/0/title", "$['store']['book'][0]['title']"},
+		{"/a~1b/c~0d/01", "$['a/b']['c~d']['01']"},
+		{"/a.b/-", "$['a.b']['-']"},
+		{"/a]b/it's/a\\b", `$['a]b']['it\'s']['a\\b']`},
+		{"/", "$['']"},
+	}
+	for _, tcase := range tcases {
+		c, err := FromPointer(tcase.ptr)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.ptr, err)
+			continue
+		}
+		if c.path != tcase.path {
+			t.Errorf("%s: expected %s, got %s", tcase.ptr, tcase.path, c.path)
+		}
+		if ptr, err := ToPointer(c); err != nil || ptr != tcase.ptr {
+			t.Errorf("%s: round trip produced %q, %v", tcase.ptr, ptr, err)
+		}
+	}
+	for _, ptr := range []string{"a", "/a~2"} {
+		if c, err := FromPointer(ptr); err == nil {
+			t.Errorf("%s: expected error, got %s", ptr, c.path)
+		}
+	}
+}
+
+func Test_ResolvePointer(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"store": {"book": [{"title": "a"}, {"title": "b"}]}, "a/b": {"0": "zero"}, "": 1}`), &doc)
+	tcases := []struct {
+		ptr   string
+		value interface{}
+	}{
+		{"", doc},
+		{"/store/book/1/title", "b"},
+		{"/a~1b/0", "zero"},
+		{"/", 1.0},
+	}
+	for _, tcase := range tcases {
+		res, err := ResolvePointer(&doc, tcase.ptr)
+		if err != nil || !reflect.DeepEqual(res, tcase.value) {
+			t.Errorf("%s: expected %v, got %v, %v", tcase.ptr, tcase.value, res, err)
+		}
+	}
+	for _, ptr := range []string{"store", "/missing", "/store/book/2", "/store/book/-", "/store/book/01", "/store/book/0/title/x"} {
+		if res, err := ResolvePointer(doc, ptr); err == nil {
+			t.Errorf("%s: expected error, got %v", ptr, res)
+		}
+	}
+
+	c, _ := FromPointer("/store/book/1/title")
+	if res, err := c.Lookup(doc); err != nil || res != "b" {
+		t.Errorf("unexpected lookup result %v, %v", res, err)
+	}
+}
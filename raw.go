@@ -0,0 +1,373 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+// LookupBytes evaluates path by scanning raw JSON document and returns raw values
+// of all matches without building map[string]interface{} tree. Returned values
+// share memory with data. Only elements checked by filters and nodes used by
+// expressions are unmarshaled.
+// The document is not validated completely, only the parts needed to find matches.
+func (c *Compiled) LookupBytes(data []byte) ([]json.RawMessage, error) {
+	r := rawScanner{data: data}
+	var res []json.RawMessage
+	_, err := r.node(r.skipSpace(0), c.selectors(), func(raw []byte) {
+		res = append(res, json.RawMessage(raw))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type rawScanner struct {
+	data []byte
+	// root is unmarshaled lazily for filters referencing it
+	root    interface{}
+	hasRoot bool
+}
+
+// node matches value starting at pos against steps, returns position after the value
+func (r *rawScanner) node(pos int, steps []step, emit func([]byte)) (int, error) {
+	if pos >= len(r.data) {
+		return 0, errUnexpectedEnd
+	}
+	if len(steps) == 0 || steps[0].op == "scan" {
+		end, err := r.valueEnd(pos)
+		if err != nil {
+			return 0, err
+		}
+		emit(r.data[pos:end])
+		return end, nil
+	}
+	s := steps[0]
+	c := r.data[pos]
+	switch {
+	case s.op == KeyOp && c == '{':
+		return r.object(pos, func(key []byte, valuePos int) (int, error) {
+			if r.keyEquals(key, s.key) {
+				return r.node(valuePos, steps[1:], emit)
+			}
+			return r.valueEnd(valuePos)
+		})
+	case s.op == KeyOp && c == '[':
+		// key is taken from every element of array
+		return r.array(pos, func(i, valuePos int) (int, error) {
+			return r.node(valuePos, steps, emit)
+		})
+	case (s.op == IndexOp || s.op == RangeOp) && c == '[':
+		var elems []int
+		end, err := r.array(pos, func(i, valuePos int) (int, error) {
+			elems = append(elems, valuePos)
+			return r.valueEnd(valuePos)
+		})
+		if err != nil {
+			return 0, err
+		}
+		var selected []int
+		if s.op == IndexOp {
+			for _, idx := range s.args.([]int) {
+				if idx < 0 {
+					idx += len(elems)
+				}
+				if idx >= 0 && idx < len(elems) {
+					selected = append(selected, idx)
+				}
+			}
+		} else {
+			args := s.args.([2]interface{})
+			if frm, to, err := rangeBounds(len(elems), args[0], args[1]); err == nil {
+				for i := frm; i < to; i++ {
+					selected = append(selected, i)
+				}
+			}
+		}
+		for _, i := range selected {
+			if _, err := r.node(elems[i], steps[1:], emit); err != nil {
+				return 0, err
+			}
+		}
+		return end, nil
+	case s.op == FilterOp && (c == '[' || c == '{'):
+		return r.filtered(pos, steps, emit)
+	case s.op == ExpressionOp:
+		return r.expression(pos, steps, emit)
+	case s.op == KeyOp || s.op == IndexOp || s.op == RangeOp || s.op == FilterOp:
+		// scalar or wrong container type could not be matched
+		return r.valueEnd(pos)
+	default:
+		return 0, fmt.Errorf("%s expression don't support in lookup", s.op)
+	}
+}
+
+// expression evaluates expression step on the node and continues by extracted key or index
+func (r *rawScanner) expression(pos int, steps []step, emit func([]byte)) (int, error) {
+	end, err := r.valueEnd(pos)
+	if err != nil {
+		return 0, err
+	}
+	var obj, root interface{}
+	if err := json.Unmarshal(r.data[pos:end], &obj); err != nil {
+		return 0, err
+	}
+	expr := steps[0].expr
+	if expr.root {
+		if root, err = r.rootObj(); err != nil {
+			return 0, err
+		}
+	}
+	key, err := expr.get(obj, root)
+	if err != nil {
+		return 0, err
+	}
+	var next step
+	if v, ok := key.(string); ok {
+		next = step{op: KeyOp, key: v}
+	} else if idx, ok := toIndex(key); ok {
+		next = step{op: IndexOp, args: []int{idx}}
+	} else {
+		return 0, fmt.Errorf("extracted invalid expression: %v", key)
+	}
+	if _, err := r.node(pos, append([]step{next}, steps[1:]...), emit); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+// filtered matches elements of array or values of object by filter step
+func (r *rawScanner) filtered(pos int, steps []step, emit func([]byte)) (int, error) {
+	f := steps[0].filter
+	var root interface{}
+	if f.referencesRoot() {
+		var err error
+		if root, err = r.rootObj(); err != nil {
+			return 0, err
+		}
+	}
+	match := func(valuePos int) (int, error) {
+		end, err := r.valueEnd(valuePos)
+		if err != nil {
+			return 0, err
+		}
+		var obj interface{}
+		if err := json.Unmarshal(r.data[valuePos:end], &obj); err != nil {
+			return 0, err
+		}
+		ok, err := f.match(obj, root)
+		if err != nil || !ok {
+			return end, err
+		}
+		return r.node(valuePos, steps[1:], emit)
+	}
+
+	if r.data[pos] == '[' {
+		return r.array(pos, func(i, valuePos int) (int, error) {
+			return match(valuePos)
+		})
+	}
+
+	// values are visited ordered by keys like Lookup does
+	type member struct {
+		key string
+		pos int
+	}
+	var members []member
+	end, err := r.object(pos, func(key []byte, valuePos int) (int, error) {
+		k, err := r.key(key)
+		if err != nil {
+			return 0, err
+		}
+		members = append(members, member{k, valuePos})
+		return r.valueEnd(valuePos)
+	})
+	if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].key < members[j].key
+	})
+	for _, m := range members {
+		if _, err := match(m.pos); err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// rootObj unmarshal the whole document when filter or expression requires it
+func (r *rawScanner) rootObj() (interface{}, error) {
+	if !r.hasRoot {
+		if err := json.Unmarshal(r.data, &r.root); err != nil {
+			return nil, err
+		}
+		r.hasRoot = true
+	}
+	return r.root, nil
+}
+
+// object calls fn for every member of object at pos, fn returns position after the value
+func (r *rawScanner) object(pos int, fn func(key []byte, valuePos int) (int, error)) (int, error) {
+	pos = r.skipSpace(pos + 1)
+	if pos < len(r.data) && r.data[pos] == '}' {
+		return pos + 1, nil
+	}
+	for {
+		if pos >= len(r.data) || r.data[pos] != '"' {
+			return 0, r.syntaxError(pos, "object key")
+		}
+		keyEnd, err := r.stringEnd(pos)
+		if err != nil {
+			return 0, err
+		}
+		key := r.data[pos+1 : keyEnd-1]
+		pos = r.skipSpace(keyEnd)
+		if pos >= len(r.data) || r.data[pos] != ':' {
+			return 0, r.syntaxError(pos, "':'")
+		}
+		if pos, err = fn(key, r.skipSpace(pos+1)); err != nil {
+			return 0, err
+		}
+		done := false
+		if pos, done, err = r.next(pos, '}'); err != nil || done {
+			return pos, err
+		}
+	}
+}
+
+// array calls fn for every element of array at pos, fn returns position after the value
+func (r *rawScanner) array(pos int, fn func(i, valuePos int) (int, error)) (int, error) {
+	pos = r.skipSpace(pos + 1)
+	if pos < len(r.data) && r.data[pos] == ']' {
+		return pos + 1, nil
+	}
+	for i := 0; ; i++ {
+		var err error
+		if pos, err = fn(i, pos); err != nil {
+			return 0, err
+		}
+		done := false
+		if pos, done, err = r.next(pos, ']'); err != nil || done {
+			return pos, err
+		}
+	}
+}
+
+// next skips separator after value and returns position of the next value.
+// When container is finished done is set and position after closing delimiter is returned.
+func (r *rawScanner) next(pos int, closing byte) (int, bool, error) {
+	pos = r.skipSpace(pos)
+	if pos >= len(r.data) {
+		return 0, false, errUnexpectedEnd
+	}
+	switch r.data[pos] {
+	case ',':
+		return r.skipSpace(pos + 1), false, nil
+	case closing:
+		return pos + 1, true, nil
+	default:
+		return 0, false, r.syntaxError(pos, fmt.Sprintf("',' or '%c'", closing))
+	}
+}
+
+// valueEnd returns position after the value starting at pos
+func (r *rawScanner) valueEnd(pos int) (int, error) {
+	if pos >= len(r.data) {
+		return 0, errUnexpectedEnd
+	}
+	switch r.data[pos] {
+	case '"':
+		return r.stringEnd(pos)
+	case '{', '[':
+		depth := 0
+		for i := pos; i < len(r.data); i++ {
+			switch r.data[i] {
+			case '"':
+				end, err := r.stringEnd(i)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+		}
+		return 0, errUnexpectedEnd
+	default:
+		end := pos
+		for end < len(r.data) {
+			c := r.data[end]
+			if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				break
+			}
+			end++
+		}
+		if end == pos {
+			return 0, r.syntaxError(pos, "value")
+		}
+		return end, nil
+	}
+}
+
+// stringEnd returns position after closing quote of string starting at pos
+func (r *rawScanner) stringEnd(pos int) (int, error) {
+	for i := pos + 1; i < len(r.data); i++ {
+		switch r.data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errUnexpectedEnd
+}
+
+func (r *rawScanner) skipSpace(pos int) int {
+	for pos < len(r.data) {
+		switch r.data[pos] {
+		case ' ', '\t', '\n', '\r':
+			pos++
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+func (r *rawScanner) keyEquals(key []byte, s string) bool {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return string(key) == s
+	}
+	k, err := r.key(key)
+	return err == nil && k == s
+}
+
+// key unescape raw object key
+func (r *rawScanner) key(key []byte) (string, error) {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return string(key), nil
+	}
+	var k string
+	quoted := make([]byte, 0, len(key)+2)
+	quoted = append(append(append(quoted, '"'), key...), '"')
+	err := json.Unmarshal(quoted, &k)
+	return k, err
+}
+
+func (r *rawScanner) syntaxError(pos int, expected string) error {
+	if pos >= len(r.data) {
+		return errUnexpectedEnd
+	}
+	return fmt.Errorf("invalid character '%c' at offset %d, expected %s", r.data[pos], pos, expected)
+}
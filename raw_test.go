@@ -0,0 +1,132 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_LookupBytes(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(stream_data), &data)
+
+	paths := []string{
+		"$",
+		"$.expensive",
+		"$.store.book[0].price",
+		"$.store.book[-1].isbn",
+		"$.store.book[2,0].author",
+		"$.store.book[1:2].price",
+		"$.store.book[-2:].price",
+		"$.store.book[*].tags[1]",
+		"$.store.book.author",
+		"$.store.book[?(@.isbn)].price",
+		"$.store.book[?(@.price > 10)].author",
+		"$.store.book[?(@.author =~ /(?i).*REES/)].price",
+		"$.store.book[?(@.price < $.expensive)].price",
+		"$.store[?(@.color == 'red')].price",
+		"$.store[($.main)].color",
+		"$['store']['bicycle']",
+		"$.nested[:1].[0].test",
+		"$.missing.key",
+		"$.store.*",
+	}
+	for _, path := range paths {
+		c := MustCompile(path)
+		var exp []interface{}
+		c.walk(data, func(loc location, v interface{}) error {
+			exp = append(exp, v)
+			return nil
+		})
+		raw, err := c.LookupBytes([]byte(stream_data))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		var got []interface{}
+		for _, r := range raw {
+			var v interface{}
+			if err := json.Unmarshal(r, &v); err != nil {
+				t.Errorf("%s: invalid raw value %s: %v", path, r, err)
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("%s: got %v, expected %v", path, got, exp)
+		}
+	}
+}
+
+func Test_LookupBytesRaw(t *testing.T) {
+	data := []byte(`{"a\"b": {"x": [1, 2.50, "s]"]}, "c": { } , "d":[ ]}`)
+	tcases := []struct {
+		path string
+		exp  []string
+	}{
+		{`$['a"b'].x`, []string{`[1, 2.50, "s]"]`}},
+		{`$['a"b'].x[1]`, []string{`2.50`}},
+		{`$['a"b'].x[2]`, []string{`"s]"`}},
+		{`$.c`, []string{`{ }`}},
+		{`$.d[0]`, nil},
+	}
+	for _, tcase := range tcases {
+		raw, err := MustCompile(tcase.path).LookupBytes(data)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.path, err)
+			continue
+		}
+		var got []string
+		for _, r := range raw {
+			got = append(got, string(r))
+		}
+		if !reflect.DeepEqual(got, tcase.exp) {
+			t.Errorf("%s: got %q, expected %q", tcase.path, got, tcase.exp)
+		}
+	}
+
+	for _, bad := range []string{`{"a": [1, 2`, `{"a" 1}`, `{"a": "b`, `{"a": [1 2]}`, ``} {
+		if _, err := MustCompile("$.a[1]").LookupBytes([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+var webhook_data = []byte(`{
+	"action": "opened",
+	"number": 2,
+	"pull_request": {"id": 279147437, "title": "Update the README", "user": {"login": "octocat", "id": 21031067}, "labels": []},
+	"repository": {"id": 186853002, "name": "Hello-World", "owner": {"login": "octocat"}, "topics": ["a", "b", "c"]},
+	"sender": {"login": "octocat", "id": 21031067}
+}`)
+
+func BenchmarkLookupBytes(b *testing.B) {
+	action := MustCompile("$.action")
+	login := MustCompile("$.pull_request.user.login")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := action.LookupBytes(webhook_data); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := login.LookupBytes(webhook_data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLookupUnmarshal(b *testing.B) {
+	action := MustCompile("$.action")
+	login := MustCompile("$.pull_request.user.login")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var data interface{}
+		if err := json.Unmarshal(webhook_data, &data); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := action.Lookup(data); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := login.Lookup(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector is a single parsed selector of compiled path
+type Selector struct {
+	// Op is one of KeyOp, IndexOp, RangeOp, FilterOp, ExpressionOp or "scan" for .*
+	Op string
+	// Key is the map key selected by KeyOp
+	Key string
+	// Indexes are selected by IndexOp
+	Indexes []int
+	// From and To are int bounds of RangeOp, nil when omitted
+	From, To interface{}
+	// Expr is the source of FilterOp and ExpressionOp
+	Expr string
+}
+
+// String returns selector in bracket notation, like ['book'] or [?(@.price < 10)]
+func (s Selector) String() string {
+	switch s.Op {
+	case KeyOp:
+		return QuoteKey(s.Key)
+	case IndexOp:
+		indexes := make([]string, len(s.Indexes))
+		for i, idx := range s.Indexes {
+			indexes[i] = strconv.Itoa(idx)
+		}
+		return "[" + strings.Join(indexes, ",") + "]"
+	case RangeOp:
+		if s.From == nil && s.To == nil {
+			return "[*]"
+		}
+		var b strings.Builder
+		b.WriteString("[")
+		if s.From != nil {
+			fmt.Fprint(&b, s.From)
+		}
+		b.WriteString(":")
+		if s.To != nil {
+			fmt.Fprint(&b, s.To)
+		}
+		b.WriteString("]")
+		return b.String()
+	case FilterOp:
+		return "[?(" + s.Expr + ")]"
+	case ExpressionOp:
+		return "[(" + s.Expr + ")]"
+	case "scan":
+		return ".*"
+	}
+	return s.Op
+}
+
+// Selectors returns parsed selectors of c, one per step of evaluation:
+// $.store.book[0] is split to ['store'], ['book'] and [0]
+func (c *Compiled) Selectors() []Selector {
+	steps := c.selectors()
+	res := make([]Selector, len(steps))
+	for i, s := range steps {
+		sel := Selector{Op: s.op, Key: s.key}
+		switch s.op {
+		case IndexOp:
+			sel.Indexes = append([]int(nil), s.args.([]int)...)
+		case RangeOp:
+			args := s.args.([2]interface{})
+			sel.From, sel.To = args[0], args[1]
+		case FilterOp, ExpressionOp:
+			sel.Expr = s.args.(string)
+		}
+		res[i] = sel
+	}
+	return res
+}
+
+// IsPlainKey reports whether key may follow dot in path, like $.key,
+// the other keys should be written by QuoteKey
+func IsPlainKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, ".[]()'\"\\*@$?, \t")
+}
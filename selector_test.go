@@ -0,0 +1,35 @@
+package jsonpath
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Selectors(t *testing.T) {
+	tcases := []struct {
+		path      string
+		selectors string
+	}{
+		{"$.store.book[0].title", "['store'] ['book'] [0] ['title']"},
+		{"$['a.b'].c[1,-1]", "['a.b'] ['c'] [1,-1]"},
+		{`$['it\'s']['a\\b']`, `['it\'s'] ['a\\b']`},
+		{"$.a[*].b[1:].c[:2].d[1:3]", "['a'] [*] ['b'] [1:] ['c'] [:2] ['d'] [1:3]"},
+		{"$.a[?(@.price < 10)][($.idx)]", "['a'] [?(@.price < 10)] [($.idx)]"},
+		{"$.*", ".*"},
+		{"$", ""},
+	}
+	for _, tcase := range tcases {
+		var res []string
+		for _, s := range MustCompile(tcase.path).Selectors() {
+			res = append(res, s.String())
+		}
+		if selectors := strings.Join(res, " "); selectors != tcase.selectors {
+			t.Errorf("%s: expected %s, got %s", tcase.path, tcase.selectors, selectors)
+		}
+	}
+
+	s := MustCompile("$.a[1:]").Selectors()[1]
+	if s.Op != RangeOp || s.From != 1 || s.To != nil {
+		t.Errorf("unexpected range selector %+v", s)
+	}
+}
@@ -0,0 +1,238 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Stream evaluates path over JSON document read from r without unmarshaling it
+// completely. Only subtrees which could be matched by the path are decoded,
+// everything else is skipped, so memory usage is proportional to a single match
+// (or a single array element for filters).
+// fn is called for every match with its normalized path, in document order.
+// Filters and expressions referencing the root object ($) require the whole
+// document, in this case it is decoded completely.
+func (c *Compiled) Stream(r io.Reader, fn func(path string, v interface{}) error) error {
+	dec := json.NewDecoder(r)
+	visit := func(loc location, v interface{}) error {
+		return fn(loc.String(), v)
+	}
+	if c.referencesRoot() {
+		var root interface{}
+		if err := dec.Decode(&root); err != nil {
+			return err
+		}
+		return c.walk(root, visit)
+	}
+	err := streamNode(dec, nil, c.selectors(), visit)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+// referencesRoot reports whether any filter or expression of c uses the root object
+func (c *Compiled) referencesRoot() bool {
+	for _, s := range c.steps {
+		switch s.op {
+		case FilterOp:
+			if s.filter.referencesRoot() {
+				return true
+			}
+		case ExpressionOp:
+			if s.expr.root {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeSteps splits steps so every step contains a single selector:
+// key steps have no sub key and the other steps have no key
+func normalizeSteps(steps []step) []step {
+	res := make([]step, 0, len(steps))
+	for _, s := range steps {
+		switch s.op {
+		case KeyOp:
+			if s.key != "" {
+				res = append(res, step{op: KeyOp, key: s.key})
+			}
+			if subKey, ok := s.args.(string); ok {
+				res = append(res, step{op: KeyOp, key: subKey})
+			}
+		case IndexOp, RangeOp, FilterOp, ExpressionOp:
+			if s.key != "" {
+				res = append(res, step{op: KeyOp, key: s.key})
+			}
+			s.key = ""
+			res = append(res, s)
+		default:
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// streamNode matches the next value of decoder against steps
+func streamNode(dec *json.Decoder, loc location, steps []step, fn func(loc location, v interface{}) error) error {
+	if streamNeedsValue(steps) {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		return walkSteps(v, nil, loc, steps, fn)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// scalar could not be matched by any selector
+		return nil
+	}
+	s := steps[0]
+	var members map[string]interface{}
+	switch delim {
+	case '{':
+		// filter on object visits values ordered by keys, so they are collected first
+		if s.op == FilterOp {
+			members = map[string]interface{}{}
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			if members != nil {
+				var v interface{}
+				err = dec.Decode(&v)
+				members[key] = v
+			} else if s.op == KeyOp && key == s.key {
+				err = streamNode(dec, loc.with(key), steps[1:], fn)
+			} else {
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; dec.More(); i++ {
+			var err error
+			switch {
+			case s.op == KeyOp:
+				// key is taken from every element of array
+				err = streamNode(dec, loc.with(i), steps, fn)
+			case s.op == FilterOp:
+				err = streamFiltered(dec, loc.with(i), steps, fn)
+			case streamSelected(s, i):
+				err = streamNode(dec, loc.with(i), steps[1:], fn)
+			default:
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// closing delimiter
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+	if members != nil {
+		return walkSteps(members, nil, loc, steps, fn)
+	}
+	return nil
+}
+
+// streamNeedsValue reports whether the next value should be decoded completely to match steps
+func streamNeedsValue(steps []step) bool {
+	if len(steps) == 0 {
+		return true
+	}
+	s := steps[0]
+	switch s.op {
+	case KeyOp, FilterOp:
+		return false
+	case IndexOp:
+		// negative indexes require length and unordered ones require random access
+		prev := -1
+		for _, idx := range s.args.([]int) {
+			if idx <= prev {
+				return true
+			}
+			prev = idx
+		}
+		return false
+	case RangeOp:
+		// negative bounds require length, range with end matches nothing when the end
+		// is out of array, which is known only after the last element
+		args := s.args.([2]interface{})
+		if _, ok := args[1].(int); ok {
+			return true
+		}
+		if v, ok := args[0].(int); ok && v < 0 {
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// streamSelected reports whether index or range step selects i-th element
+func streamSelected(s step, i int) bool {
+	switch s.op {
+	case IndexOp:
+		for _, idx := range s.args.([]int) {
+			if idx == i {
+				return true
+			}
+		}
+	case RangeOp:
+		// ranges with end are matched on decoded arrays
+		args := s.args.([2]interface{})
+		if frm, ok := args[0].(int); ok && i < frm {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// streamFiltered decodes array element and matches it by filter step
+func streamFiltered(dec *json.Decoder, loc location, steps []step, fn func(loc location, v interface{}) error) error {
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	ok, err := steps[0].filter.match(v, nil)
+	if err != nil || !ok {
+		return err
+	}
+	return walkSteps(v, nil, loc, steps[1:], fn)
+}
+
+// skipValue reads the next value from decoder without keeping it
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var stream_data = `{
+	"main": "bicycle",
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "price": 12.99, "tags": ["a", "b"]},
+			{"category": "fiction", "author": "Herman Melville", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"nested": [[{"test": 1.1}, {"test": 2.1}], [{"test": 3.1}]],
+	"expensive": 10
+}`
+
+func Test_Stream(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(stream_data), &data)
+
+	paths := []string{
+		"$",
+		"$.expensive",
+		"$.store.book[0].price",
+		"$.store.book[-1].isbn",
+		"$.store.book[0,2].author",
+		"$.store.book[2,0].author",
+		"$.store.book[1:2].price",
+		"$.store.book[-2:].price",
+		"$.store.book[1:3].price",
+		"$.store.book[2:5].price",
+		"$.nested[0][1:3]",
+		"$.nested[1:]",
+		"$.store.book[*].tags[1]",
+		"$.store.book.author",
+		"$.store.book[?(@.isbn)].price",
+		"$.store.book[?(@.price > 10)].author",
+		"$.store.book[?(@.author =~ /(?i).*REES/)].price",
+		"$.store.book[?(@.price < $.expensive)].price",
+		"$.store[?(@.color == 'red')].price",
+		"$.store[($.main)].color",
+		"$['store']['bicycle']",
+		"$.nested[:1].[0].test",
+		"$.missing.key",
+	}
+	for _, path := range paths {
+		c := MustCompile(path)
+		var expPaths, gotPaths []string
+		var exp, got []interface{}
+		c.walk(data, func(loc location, v interface{}) error {
+			expPaths = append(expPaths, loc.String())
+			exp = append(exp, v)
+			return nil
+		})
+		err := c.Stream(strings.NewReader(stream_data), func(path string, v interface{}) error {
+			gotPaths = append(gotPaths, path)
+			got = append(got, v)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !reflect.DeepEqual(gotPaths, expPaths) || !reflect.DeepEqual(got, exp) {
+			t.Errorf("%s: got %v %v, expected %v %v", path, gotPaths, got, expPaths, exp)
+		}
+	}
+}
+
+func Test_StreamStop(t *testing.T) {
+	stop := errors.New("stop")
+	count := 0
+	err := MustCompile("$.store.book[*].author").Stream(strings.NewReader(stream_data), func(path string, v interface{}) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("stream was not stopped: %v, %d", err, count)
+	}
+
+	err = MustCompile("$.store.book[*].author").Stream(strings.NewReader(`{"store": {"book": [{"author": 1}, `), func(path string, v interface{}) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("expected error for truncated document")
+	}
+}
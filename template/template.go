@@ -0,0 +1,318 @@
+// Package template renders kubectl-style JSONPath templates.
+//
+// Template is literal text with expressions in braces:
+//
+//	{range .items[*]}{.metadata.name}{"\t"}{.status.phase}{"\n"}{end}
+//
+// Expressions are:
+//
+//	{.path} {[0].path}   path relative to the current node, $ is implied
+//	{@} {@.path}         the current node or path relative to it
+//	{$.path}             path from the root of document
+//	{"text"}             quoted string with Go escapes
+//	{range path} {end}   renders the body for every node matched by path or every element
+//	                     of a single matched array
+//
+// Inside of range the current node is the element of range, outside it's the root of document.
+// Strings are written as is, the other values are encoded as JSON and values of several
+// matched nodes, like {.items[*].name} or {.items.name} over array, are separated by space.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ilyaferilo/jsonpath"
+)
+
+// Template is a parsed template, it's safe for concurrent use
+type Template struct {
+	name         string
+	nodes        []node
+	allowMissing bool
+}
+
+// Parse parses template text, name is used in errors
+func Parse(name, text string) (*Template, error) {
+	p := &parser{name: name, text: text}
+	nodes, err := p.parse(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{name: name, nodes: nodes}, nil
+}
+
+// Must panics if err is not nil, like text/template.Must
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Name returns name of the template
+func (t *Template) Name() string {
+	return t.name
+}
+
+// AllowMissingKeys makes paths without results render nothing instead of returning error
+func (t *Template) AllowMissingKeys(allow bool) *Template {
+	t.allowMissing = allow
+	return t
+}
+
+// Execute renders template for data to w
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	s := &state{t: t, w: w, root: data}
+	return s.walk(t.nodes, data)
+}
+
+type node interface{}
+
+// textNode is literal text or quoted string
+type textNode string
+
+// pathNode is a path evaluated against the current node or root of document
+type pathNode struct {
+	expr string
+	c    *jsonpath.Compiled
+	// root is set for paths starting with $
+	root bool
+}
+
+type rangeNode struct {
+	path pathNode
+	body []node
+}
+
+type parser struct {
+	name string
+	text string
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("template %s: %s", p.name, fmt.Sprintf(format, args...))
+}
+
+// parse parses nodes until the end of text or {end} when inRange is set
+func (p *parser) parse(inRange bool) ([]node, error) {
+	var nodes []node
+	for p.pos < len(p.text) {
+		open := strings.IndexByte(p.text[p.pos:], '{')
+		if open < 0 {
+			nodes = append(nodes, textNode(p.text[p.pos:]))
+			p.pos = len(p.text)
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, textNode(p.text[p.pos:p.pos+open]))
+		}
+		start := p.pos + open
+		end, err := p.closing(start + 1)
+		if err != nil {
+			return nil, err
+		}
+		expr := strings.TrimSpace(p.text[start+1 : end])
+		p.pos = end + 1
+
+		switch {
+		case expr == "":
+			return nil, p.errorf("empty expression at %d", start)
+		case expr == "end":
+			if !inRange {
+				return nil, p.errorf("unexpected {end} at %d", start)
+			}
+			return nodes, nil
+		case strings.HasPrefix(expr, "range ") || strings.HasPrefix(expr, "range\t"):
+			path, err := p.path(strings.TrimSpace(expr[len("range"):]))
+			if err != nil {
+				return nil, err
+			}
+			body, err := p.parse(true)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, rangeNode{path: path, body: body})
+			continue
+		case expr[0] == '"':
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, p.errorf("invalid string %s at %d", expr, start)
+			}
+			nodes = append(nodes, textNode(text))
+		default:
+			path, err := p.path(expr)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, path)
+		}
+	}
+	if inRange {
+		return nil, p.errorf("missing {end} of range")
+	}
+	return nodes, nil
+}
+
+// closing returns position of the brace closing expression which starts at pos,
+// braces in quoted strings are skipped
+func (p *parser) closing(pos int) (int, error) {
+	var quote byte
+	for i := pos; i < len(p.text); i++ {
+		c := p.text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i, nil
+		case c == '{':
+			return 0, p.errorf("unexpected { in expression at %d", i)
+		}
+	}
+	return 0, p.errorf("unclosed expression at %d", pos-1)
+}
+
+// path compiles expression, paths without $ or @ are relative to the current node
+func (p *parser) path(expr string) (pathNode, error) {
+	res := pathNode{expr: expr}
+	jpath := expr
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		res.root = true
+	case strings.HasPrefix(expr, "@"):
+		jpath = "$" + expr[1:]
+	case strings.HasPrefix(expr, ".") || strings.HasPrefix(expr, "["):
+		jpath = "$" + expr
+	default:
+		jpath = "$." + expr
+	}
+	c, err := jsonpath.Compile(jpath)
+	if err != nil {
+		return res, p.errorf("%s: %v", expr, err)
+	}
+	res.c = c
+	return res, nil
+}
+
+// state of a single Execute call
+type state struct {
+	t    *Template
+	w    io.Writer
+	root interface{}
+}
+
+func (s *state) walk(nodes []node, cur interface{}) error {
+	for _, n := range nodes {
+		var err error
+		switch n := n.(type) {
+		case textNode:
+			_, err = io.WriteString(s.w, string(n))
+		case pathNode:
+			err = s.print(n, cur)
+		case rangeNode:
+			err = s.rangeOver(n, cur)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns values of nodes matched by path, like kubectl every matched node is a result,
+// so a key of array elements matches several nodes and the array itself is a single node.
+// Nothing is returned when path matched nothing and missing keys are allowed.
+func (s *state) lookup(n pathNode, cur interface{}) ([]interface{}, error) {
+	obj := cur
+	if n.root {
+		obj = s.root
+	}
+	var values []interface{}
+	err := n.c.Each(obj, func(node jsonpath.Node) bool {
+		values = append(values, node.Value)
+		return true
+	})
+	if err == nil && len(values) == 0 {
+		// Lookup explains why nothing matched, empty results of filters are not errors
+		_, err = n.c.Lookup(obj)
+	}
+	if err != nil {
+		if s.t.allowMissing && jsonpath.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("template %s: %s: %v", s.t.name, n.expr, err)
+	}
+	return values, nil
+}
+
+// print writes values of matched nodes separated by spaces
+func (s *state) print(n pathNode, cur interface{}) error {
+	values, err := s.lookup(n, cur)
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		if i > 0 {
+			if _, err := io.WriteString(s.w, " "); err != nil {
+				return err
+			}
+		}
+		text, err := format(v)
+		if err != nil {
+			return fmt.Errorf("template %s: %s: %v", s.t.name, n.expr, err)
+		}
+		if _, err := io.WriteString(s.w, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeOver renders body for every matched node, elements of a single matched slice are iterated
+func (s *state) rangeOver(n rangeNode, cur interface{}) error {
+	values, err := s.lookup(n.path, cur)
+	if err != nil {
+		return err
+	}
+	if len(values) == 1 {
+		v := reflect.ValueOf(values[0])
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return s.walk(n.body, values[0])
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := s.walk(n.body, v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, v := range values {
+		if err := s.walk(n.body, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// format returns strings as is and encodes the other values as JSON
+func format(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
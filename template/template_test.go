@@ -0,0 +1,114 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const podsJSON = `{
+	"kind": "List",
+	"items": [
+		{"metadata": {"name": "web", "labels": {"app": "web"}}, "status": {"phase": "Running", "restarts": 0},
+		 "spec": {"containers": [{"name": "nginx", "ports": [80, 443]}, {"name": "sidecar"}]}},
+		{"metadata": {"name": "db"}, "status": {"phase": "Pending", "restarts": 2.5},
+		 "spec": {"containers": [{"name": "postgres", "ports": [5432]}]}}
+	]
+}`
+
+func Test_Execute(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(podsJSON), &data)
+	tcases := []struct {
+		template string
+		output   string
+	}{
+		{"{.kind}", "List"},
+		{"kind: {.kind}\n", "kind: List\n"},
+		{"{kind}", "List"},
+		{"{.items[*].metadata.name}", "web db"},
+		{"{.items.metadata.name}", "web db"},
+		{"{.items[*].spec.containers.name}", "nginx sidecar postgres"},
+		{"{.items[?(@.status.phase == 'Running')].spec.containers}", `[{"name":"nginx","ports":[80,443]},{"name":"sidecar"}]`},
+		{"{.items[0].status.restarts} {.items[1].status.restarts}", "0 2.5"},
+		{"{.items[0].metadata.labels}", `{"app":"web"}`},
+		{"{.items[0].spec.containers[0].ports}", "[80,443]"},
+		{`{range .items[*]}{.metadata.name}{"\t"}{.status.phase}{"\n"}{end}`, "web\tRunning\ndb\tPending\n"},
+		{`{range .items}{@.metadata.name},{end}`, "web,db,"},
+		{`{range .items.metadata}{.name},{end}`, "web,db,"},
+		{`{range .items[*]}[{range .spec.containers[*]}{.name}:{$.kind} {end}]{end}`, "[nginx:List sidecar:List ][postgres:List ]"},
+		{`{range .items[?(@.status.phase == 'Running')]}{.metadata.name}{end}`, "web"},
+		{`{.items[?(@.status.restarts > 1)].metadata.name}`, "db"},
+		{`{range .items[0]}{.metadata.name}{end}`, "web"},
+		{`{"{\"}"}`, `{"}`},
+		{`{.items[0].metadata['name']}`, "web"},
+		{`{ .kind }{@.kind}{$.kind}`, "ListListList"},
+	}
+	for _, tcase := range tcases {
+		tmpl, err := Parse("test", tcase.template)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.template, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			t.Errorf("%s: %v", tcase.template, err)
+			continue
+		}
+		if buf.String() != tcase.output {
+			t.Errorf("%s: expected %q, got %q", tcase.template, tcase.output, buf.String())
+		}
+	}
+}
+
+func Test_ExecuteMissingKeys(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(podsJSON), &data)
+	text := `{range .items[*]}{.metadata.name}={.metadata.labels.app}{.spec.containers[5].name};{end}`
+
+	tmpl := Must(Parse("labels", text))
+	err := tmpl.Execute(&bytes.Buffer{}, data)
+	if err == nil || !strings.Contains(err.Error(), "template labels: .spec.containers[5].name: index out of range") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.AllowMissingKeys(true).Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "web=web;db=;" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	for _, text := range []string{
+		"{.kind",
+		"{}",
+		"{end}",
+		"{range .items[*]}{.name}",
+		"{range .items[*]}{.name}{end}{end}",
+		`{"\q"}`,
+		"{.items[}",
+		"{.a{.b}}",
+	} {
+		if _, err := Parse("bad", text); err == nil || !strings.HasPrefix(err.Error(), "template bad: ") {
+			t.Errorf("%s: expected parse error, got %v", text, err)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func Test_ExecuteWriteError(t *testing.T) {
+	err := Must(Parse("w", "text")).Execute(failingWriter{}, nil)
+	if err == nil || err.Error() != "write failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
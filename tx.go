@@ -0,0 +1,310 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Tx collects Set, Del, Append and Move operations which are applied
+// to the document all-or-nothing by Commit.
+type Tx struct {
+	obj interface{}
+	ops []txOp
+}
+
+type txOp struct {
+	op    string
+	path  string
+	from  string
+	value interface{}
+}
+
+// TxError is returned by Commit and contains index of the failed operation
+type TxError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("transaction operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+// NewTx starts transaction on obj, obj is not modified until Commit
+func NewTx(obj interface{}) *Tx {
+	return &Tx{obj: obj}
+}
+
+// Set adds Set operation to the transaction
+func (tx *Tx) Set(path string, value interface{}) *Tx {
+	tx.ops = append(tx.ops, txOp{op: "set", path: path, value: value})
+	return tx
+}
+
+// Del adds Del operation to the transaction
+func (tx *Tx) Del(path string) *Tx {
+	tx.ops = append(tx.ops, txOp{op: "del", path: path})
+	return tx
+}
+
+// Append adds Append operation to the transaction
+func (tx *Tx) Append(path string, value interface{}) *Tx {
+	tx.ops = append(tx.ops, txOp{op: "append", path: path, value: value})
+	return tx
+}
+
+// Move adds operation which sets value found at from to path and deletes from
+func (tx *Tx) Move(from, path string) *Tx {
+	tx.ops = append(tx.ops, txOp{op: "move", path: path, from: from})
+	return tx
+}
+
+// Commit validates all operations and applies them in order.
+// If any operation fails, the document is restored to its original state
+// and *TxError is returned. Operations are cleared after Commit.
+func (tx *Tx) Commit() error {
+	ops := tx.ops
+	tx.ops = nil
+	for i, op := range ops {
+		if err := op.validate(tx.obj); err != nil {
+			return &TxError{Index: i, Op: op.op, Path: op.path, Err: err}
+		}
+	}
+
+	var undo txUndo
+	undo.snapshotRoot(tx.obj)
+	for i, op := range ops {
+		err := undo.snapshot(tx.obj, op)
+		if err == nil {
+			err = op.apply(tx.obj)
+		}
+		if err != nil {
+			undo.rollback()
+			return &TxError{Index: i, Op: op.op, Path: op.path, Err: err}
+		}
+	}
+	return nil
+}
+
+func (op txOp) validate(obj interface{}) error {
+	paths := []string{op.path}
+	if op.op == "move" {
+		paths = append(paths, op.from)
+	}
+	for _, path := range paths {
+		c, err := DefaultCache.Compile(path)
+		if err != nil {
+			return err
+		}
+		// Append replaces root slice behind pointer, it is restored by rollback
+		if len(c.steps) == 0 && op.op != "append" {
+			return fmt.Errorf("could not %s root object", op.op)
+		}
+		for _, s := range c.steps {
+			switch s.op {
+			case KeyOp, IndexOp:
+			case FilterOp, ExpressionOp:
+				if op.op != "set" {
+					return fmt.Errorf("not support %s operation %s", op.op, s.op)
+				}
+			default:
+				return fmt.Errorf("not support %s operation %s", op.op, s.op)
+			}
+		}
+		if op.op == "set" {
+			if err := checkSet(obj, c, op.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSet returns *TypeError when value could not be stored in typed slices or maps
+// selected by path, Set would panic on such assignment in the middle of Commit
+func checkSet(obj interface{}, c *Compiled, value interface{}) error {
+	value = followPtr(value)
+	check := func(container interface{}) error {
+		switch container.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil
+		}
+		val := reflect.ValueOf(container)
+		if val.Kind() != reflect.Map && val.Kind() != reflect.Slice {
+			return nil
+		}
+		elemType := val.Type().Elem()
+		if value == nil || !reflect.TypeOf(value).AssignableTo(elemType) {
+			return &TypeError{Value: value, Type: elemType}
+		}
+		return nil
+	}
+	// existing nodes are replaced in their containers
+	err := c.walk(obj, func(loc location, v interface{}) error {
+		if len(loc) == 0 {
+			return nil
+		}
+		parent := followPtr(obj)
+		for _, e := range loc[:len(loc)-1] {
+			parent = locationChild(parent, e)
+		}
+		return check(followPtr(parent))
+	})
+	if err != nil {
+		return err
+	}
+	// missing keys are added to maps selected by parent path
+	last := c.steps[len(c.steps)-1]
+	if last.op != KeyOp {
+		return nil
+	}
+	parents := &Compiled{path: c.path, steps: c.steps[:len(c.steps)-1]}
+	return parents.walk(obj, func(loc location, v interface{}) error {
+		if reflect.ValueOf(followPtr(v)).Kind() != reflect.Map {
+			return nil
+		}
+		return check(followPtr(v))
+	})
+}
+
+func (op txOp) apply(obj interface{}) error {
+	switch op.op {
+	case "set":
+		return Set(obj, op.path, op.value)
+	case "del":
+		return Del(obj, op.path)
+	case "append":
+		return Append(obj, op.path, op.value)
+	case "move":
+		value, err := JsonPathLookup(obj, op.from)
+		if err != nil {
+			return err
+		}
+		if err := Del(obj, op.from); err != nil {
+			return err
+		}
+		return Set(obj, op.path, value)
+	default:
+		return fmt.Errorf("unknown operation %s", op.op)
+	}
+}
+
+// txUndo keeps shallow copies of containers which may be modified by operations
+// and the value behind the root pointer, which is replaced by operations on root slices
+type txUndo struct {
+	originals []reflect.Value
+	copies    []reflect.Value
+	root      reflect.Value
+	rootValue reflect.Value
+}
+
+// snapshotRoot saves the value behind the root pointer like setRoot finds it
+func (u *txUndo) snapshotRoot(obj interface{}) {
+	rootVal := reflect.ValueOf(obj)
+	if rootVal.Kind() != reflect.Ptr || rootVal.IsNil() {
+		return
+	}
+	for rootVal.Elem().Kind() == reflect.Ptr && !rootVal.Elem().IsNil() {
+		rootVal = rootVal.Elem()
+	}
+	u.root = rootVal.Elem()
+	u.rootValue = reflect.New(u.root.Type()).Elem()
+	u.rootValue.Set(u.root)
+}
+
+// snapshot saves every container on the way to the nodes affected by op
+func (u *txUndo) snapshot(obj interface{}, op txOp) error {
+	paths := []string{op.path}
+	if op.op == "move" {
+		paths = append(paths, op.from)
+	}
+	w := newCowWriter()
+	seen := map[cowKey]bool{}
+	save := func(v interface{}) {
+		val := reflect.ValueOf(followPtr(v))
+		if val.Kind() != reflect.Map && val.Kind() != reflect.Slice {
+			return
+		}
+		if seen[w.key(val)] {
+			return
+		}
+		seen[w.key(val)] = true
+		u.originals = append(u.originals, val)
+		u.copies = append(u.copies, w.own(val))
+	}
+	saveLocation := func(loc location, v interface{}) error {
+		node := followPtr(obj)
+		save(node)
+		for _, e := range loc {
+			node = locationChild(node, e)
+			save(node)
+		}
+		// Set applies key to every element of slice
+		if val := reflect.ValueOf(followPtr(v)); val.Kind() == reflect.Slice {
+			for i := 0; i < val.Len(); i++ {
+				save(val.Index(i).Interface())
+			}
+		}
+		return nil
+	}
+	for _, path := range paths {
+		c, err := DefaultCache.Compile(path)
+		if err != nil {
+			return err
+		}
+		if len(c.steps) > 0 {
+			parents := &Compiled{path: c.path, steps: c.steps[:len(c.steps)-1]}
+			if err := parents.walk(obj, saveLocation); err != nil {
+				return err
+			}
+		}
+		if err := c.walk(obj, saveLocation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback restores content of saved containers in place
+func (u *txUndo) rollback() {
+	for i := len(u.originals) - 1; i >= 0; i-- {
+		orig, saved := u.originals[i], u.copies[i]
+		switch orig.Kind() {
+		case reflect.Map:
+			for _, k := range orig.MapKeys() {
+				orig.SetMapIndex(k, reflect.Value{})
+			}
+			for _, k := range saved.MapKeys() {
+				orig.SetMapIndex(k, saved.MapIndex(k))
+			}
+		case reflect.Slice:
+			reflect.Copy(orig, saved)
+		}
+	}
+	if u.root.IsValid() {
+		u.root.Set(u.rootValue)
+	}
+	u.originals = nil
+	u.copies = nil
+}
+
+// locationChild returns child of obj by location element
+func locationChild(obj interface{}, e interface{}) interface{} {
+	objVal := reflect.ValueOf(followPtr(obj))
+	switch objVal.Kind() {
+	case reflect.Map:
+		keyVal, err := convertValue(objVal.Type().Key(), e)
+		if err != nil {
+			return nil
+		}
+		if v := objVal.MapIndex(keyVal); v.IsValid() {
+			return v.Interface()
+		}
+	case reflect.Slice:
+		if idx, ok := e.(int); ok && idx >= 0 && idx < objVal.Len() {
+			return objVal.Index(idx).Interface()
+		}
+	}
+	return nil
+}
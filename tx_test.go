@@ -0,0 +1,141 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_Tx(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"firstname": "seth",
+			"lastname":  "rogen",
+		},
+		"movies": []string{"This Is The End", "Superbad"},
+		"old":    "value",
+	}
+	err := NewTx(&data).
+		Set("$.user.firstname", "james").
+		Append("$.movies", "Neighbors").
+		Del("$.movies[0]").
+		Move("$.old", "$.new").
+		Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{
+		"user": map[string]interface{}{
+			"firstname": "james",
+			"lastname":  "rogen",
+		},
+		"movies": []string{"Superbad", "Neighbors"},
+		"new":    "value",
+	}
+	if !reflect.DeepEqual(data, exp) {
+		t.Errorf("got: %v", data)
+	}
+}
+
+func Test_TxRollback(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{
+		"a": {"b": [1, 2, 3], "c": {"d": "e"}},
+		"books": [{"price": 5}, {"price": 15}, {"price": 25, "title": "x"}],
+		"values": [1, 2]
+	}`), &data)
+	orig := deepCopy(data)
+
+	tx := NewTx(&data).
+		Set("$.a.c.d", "f").
+		Del("$.a.b[1]").
+		Set("$.books[?(@.price > 10)].title", "expensive").
+		Append("$.values", 3).
+		Set("$.a.x", map[string]interface{}{"y": 1}).
+		Del("$.missing")
+	err := tx.Commit()
+	txErr, ok := err.(*TxError)
+	if !ok {
+		t.Fatalf("expected *TxError, got: %v", err)
+	}
+	if txErr.Index != 5 {
+		t.Errorf("unexpected failed index: %v", txErr)
+	}
+	if !reflect.DeepEqual(data, orig) {
+		t.Errorf("document was not restored:\n%v\n%v", data, orig)
+	}
+}
+
+func Test_TxValidate(t *testing.T) {
+	data := map[string]interface{}{"a": []interface{}{1, 2}}
+	err := NewTx(data).
+		Set("$.b", 1).
+		Del("$.a[0:1]").
+		Commit()
+	if txErr, ok := err.(*TxError); !ok || txErr.Index != 1 {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+	if _, found := data["b"]; found {
+		t.Error("operation applied before validation")
+	}
+	if err := NewTx(data).Set("$.b[", 1).Commit(); err == nil {
+		t.Error("expected compile error")
+	}
+}
+
+func Test_TxRollbackRoot(t *testing.T) {
+	data := []interface{}{1, 2, 3}
+	err := NewTx(&data).Del("$[0]").Del("$[10]").Commit()
+	if txErr, ok := err.(*TxError); !ok || txErr.Index != 1 {
+		t.Fatalf("expected *TxError of second operation, got: %v", err)
+	}
+	if !reflect.DeepEqual(data, []interface{}{1, 2, 3}) {
+		t.Errorf("root was not restored: %v", data)
+	}
+
+	if err := NewTx(&data).Append("$", 4).Del("$[10]").Commit(); err == nil {
+		t.Fatal("expected error")
+	}
+	if !reflect.DeepEqual(data, []interface{}{1, 2, 3}) {
+		t.Errorf("root was not restored: %v", data)
+	}
+	if err := NewTx(&data).Append("$", 4).Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, []interface{}{1, 2, 3, 4}) {
+		t.Errorf("unexpected root: %v", data)
+	}
+}
+
+func Test_TxValidateTypes(t *testing.T) {
+	data := map[string]interface{}{
+		"names":  []string{"a", "b"},
+		"counts": map[string]int{"a": 1},
+	}
+	tcases := []struct {
+		path  string
+		value interface{}
+	}{
+		{"$.names[0]", 1},
+		{"$.names[1]", nil},
+		{"$.counts.a", "x"},
+		{"$.counts.b", "x"},
+	}
+	for _, tcase := range tcases {
+		err := NewTx(&data).Set("$.x", 1).Set(tcase.path, tcase.value).Commit()
+		txErr, ok := err.(*TxError)
+		if !ok || txErr.Index != 1 {
+			t.Errorf("%s: expected *TxError, got: %v", tcase.path, err)
+			continue
+		}
+		if _, ok := txErr.Err.(*TypeError); !ok {
+			t.Errorf("%s: expected *TypeError, got: %v", tcase.path, txErr.Err)
+		}
+	}
+	if _, found := data["x"]; found {
+		t.Error("operation applied before validation")
+	}
+	if err := NewTx(&data).Set("$.names[0]", "c").Set("$.counts.b", 2).Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,343 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// location is a position of the node in the document.
+// Contains string keys of maps and int indexes of slices.
+type location []interface{}
+
+// String returns normalized path of the location, like $['store']['book'][0]
+func (l location) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, e := range l {
+		switch v := e.(type) {
+		case int:
+			b.WriteString("[")
+			b.WriteString(strconv.Itoa(v))
+			b.WriteString("]")
+		default:
+			b.WriteString(QuoteKey(fmt.Sprint(v)))
+		}
+	}
+	return b.String()
+}
+
+// QuoteKey returns key in bracket notation, like ['a.b'], quotes and backslashes of key are escaped:
+// ['it\'s']
+func QuoteKey(key string) string {
+	var b strings.Builder
+	b.WriteString("['")
+	for i := 0; i < len(key); i++ {
+		if key[i] == '\'' || key[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(key[i])
+	}
+	b.WriteString("']")
+	return b.String()
+}
+
+// unquoteKey reverts escaping of QuoteKey, the other backslashes are kept
+func unquoteKey(key string) string {
+	if !strings.Contains(key, "\\") {
+		return key
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] == '\\' && i+1 < len(key) && (key[i+1] == '\'' || key[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// tokens converts location to JSON Pointer reference tokens
+func (l location) tokens() []string {
+	res := make([]string, len(l))
+	for i, e := range l {
+		res[i] = fmt.Sprint(e)
+	}
+	return res
+}
+
+// with returns new location extended by e, l is never modified
+func (l location) with(e interface{}) location {
+	return append(l[:len(l):len(l)], e)
+}
+
+// pathElem is a map key or a slice index when idx >= 0
+type pathElem struct {
+	key string
+	idx int
+}
+
+// errStopWalk may be returned from walk callback to stop without error
+var errStopWalk = errors.New("stop walk")
+
+// walk calls fn for every node matched by c.
+// Unlike Lookup, missing keys and out of range indexes are skipped instead of
+// being reported, and each matched node is visited separately.
+func (c *Compiled) walk(rootObj interface{}, fn func(loc location, v interface{}) error) error {
+	rootObj = followPtr(rootObj)
+	err := walkSteps(rootObj, rootObj, nil, c.selectors(), fn)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+// walkSteps matches obj at loc against normalized steps
+func walkSteps(obj, rootObj interface{}, loc location, steps []step, fn func(loc location, v interface{}) error) error {
+	w := &walker{root: rootObj, path: make([]pathElem, len(loc), len(loc)+len(steps))}
+	for i, e := range loc {
+		if idx, ok := e.(int); ok {
+			w.path[i] = pathElem{idx: idx}
+		} else {
+			w.path[i] = pathElem{key: fmt.Sprint(e), idx: -1}
+		}
+	}
+	w.fn = func(w *walker, v interface{}) error {
+		return fn(w.location(), v)
+	}
+	return w.steps(obj, steps)
+}
+
+// walker keeps path of the current node in a stack,
+// so nothing is allocated while descending into the document
+type walker struct {
+	root interface{}
+	path []pathElem
+	fn   func(w *walker, v interface{}) error
+}
+
+// location returns copy of the current path
+func (w *walker) location() location {
+	loc := make(location, len(w.path))
+	for i, e := range w.path {
+		if e.idx >= 0 {
+			loc[i] = e.idx
+		} else {
+			loc[i] = e.key
+		}
+	}
+	return loc
+}
+
+func (w *walker) steps(obj interface{}, steps []step) error {
+	if len(steps) == 0 {
+		return w.fn(w, obj)
+	}
+	obj = followPtr(obj)
+	s := steps[0]
+	switch s.op {
+	case KeyOp:
+		return w.key(obj, s.key, steps)
+	case IndexOp:
+		length, ok := sliceLen(obj)
+		if !ok {
+			return nil
+		}
+		for _, idx := range s.args.([]int) {
+			idx, err := sliceIndex(length, idx)
+			if err != nil {
+				continue
+			}
+			if err := w.child(sliceElem(obj, idx), pathElem{idx: idx}, steps[1:]); err != nil {
+				return err
+			}
+		}
+	case RangeOp:
+		length, ok := sliceLen(obj)
+		if !ok {
+			return nil
+		}
+		args := s.args.([2]interface{})
+		frm, to, err := rangeBounds(length, args[0], args[1])
+		if err != nil {
+			return nil
+		}
+		for i := frm; i < to; i++ {
+			if err := w.child(sliceElem(obj, i), pathElem{idx: i}, steps[1:]); err != nil {
+				return err
+			}
+		}
+	case FilterOp:
+		return w.filtered(obj, s.filter, steps[1:])
+	case ExpressionOp:
+		key, err := s.expr.get(obj, w.root)
+		if err != nil {
+			return err
+		}
+		if v, ok := key.(string); ok {
+			return w.key(obj, v, append([]step{{op: KeyOp, key: v}}, steps[1:]...))
+		}
+		if idx, ok := toIndex(key); ok {
+			return w.steps(obj, append([]step{{op: IndexOp, args: []int{idx}}}, steps[1:]...))
+		}
+		return fmt.Errorf("extracted invalid expression: %v", key)
+	case "scan":
+		return w.fn(w, obj)
+	default:
+		return fmt.Errorf("%s expression don't support in walk", s.op)
+	}
+	return nil
+}
+
+// key visits map value by key, for slices key is taken from every element.
+// steps[0] is the key step itself.
+func (w *walker) key(obj interface{}, key string, steps []step) error {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		if val, exists := v[key]; exists {
+			return w.child(val, pathElem{key: key, idx: -1}, steps[1:])
+		}
+		return nil
+	case []interface{}:
+		for i, elem := range v {
+			if err := w.child(elem, pathElem{idx: i}, steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	objVal := reflect.ValueOf(obj)
+	switch objVal.Kind() {
+	case reflect.Map:
+		for _, kv := range objVal.MapKeys() {
+			if kv.String() == key {
+				return w.child(objVal.MapIndex(kv).Interface(), pathElem{key: key, idx: -1}, steps[1:])
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < objVal.Len(); i++ {
+			if err := w.child(objVal.Index(i).Interface(), pathElem{idx: i}, steps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// filtered visits slice elements or map values ordered by keys which are matched by filter
+func (w *walker) filtered(obj interface{}, f *filter, steps []step) error {
+	switch v := obj.(type) {
+	case []interface{}:
+		for i, child := range v {
+			if err := w.matched(child, pathElem{idx: i}, f, steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if err := w.matched(v[key], pathElem{key: key, idx: -1}, f, steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walkChildren(obj, nil, func(child interface{}, loc location) error {
+		e := pathElem{idx: -1}
+		if idx, ok := loc[0].(int); ok {
+			e.idx = idx
+		} else {
+			e.key = loc[0].(string)
+		}
+		return w.matched(child, e, f, steps)
+	})
+}
+
+// matched descends into the child node if it's matched by filter
+func (w *walker) matched(obj interface{}, e pathElem, f *filter, steps []step) error {
+	ok, err := f.match(obj, w.root)
+	if err != nil || !ok {
+		return err
+	}
+	return w.child(obj, e, steps)
+}
+
+// child descends into the child node at e
+func (w *walker) child(obj interface{}, e pathElem, steps []step) error {
+	w.path = append(w.path, e)
+	err := w.steps(obj, steps)
+	w.path = w.path[:len(w.path)-1]
+	return err
+}
+
+// sliceLen returns length of slice, ok is false when obj is not a slice
+func sliceLen(obj interface{}) (int, bool) {
+	if arr, ok := obj.([]interface{}); ok {
+		return len(arr), true
+	}
+	objVal := reflect.ValueOf(obj)
+	if objVal.Kind() != reflect.Slice {
+		return 0, false
+	}
+	return objVal.Len(), true
+}
+
+// sliceElem returns i-th element of slice
+func sliceElem(obj interface{}, i int) interface{} {
+	if arr, ok := obj.([]interface{}); ok {
+		return arr[i]
+	}
+	return reflect.ValueOf(obj).Index(i).Interface()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walkChildren visits slice elements or map values ordered by keys
+func walkChildren(obj interface{}, loc location, fn func(interface{}, location) error) error {
+	switch v := obj.(type) {
+	case []interface{}:
+		for i, child := range v {
+			if err := fn(child, loc.with(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if err := fn(v[key], loc.with(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	objVal := reflect.ValueOf(obj)
+	switch objVal.Kind() {
+	case reflect.Slice:
+		for i := 0; i < objVal.Len(); i++ {
+			if err := fn(objVal.Index(i).Interface(), loc.with(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := objVal.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, kv := range keys {
+			if err := fn(objVal.MapIndex(kv).Interface(), loc.with(fmt.Sprint(kv.Interface()))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_walk(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{
+		"a": [[{"test": 1.1}, {"test": 2.1}], [{"test": 3.1}, {"test": 4.1}]],
+		"b": {"x": {"v": 1}, "y": {"v": 2}, "z": {"v": 3}},
+		"c": [{"n": 1}, {"m": 2}, {"n": 3}]
+	}`), &data)
+
+	tcases := []struct {
+		path   string
+		locs   []string
+		values []interface{}
+	}{
+		{"$.a[:1].[0].test", []string{"$['a'][0][0]['test']", "$['a'][1][0]['test']"}, []interface{}{1.1, 3.1}},
+		{"$.a[-1][1]", []string{"$['a'][1][1]"}, []interface{}{map[string]interface{}{"test": 4.1}}},
+		{"$.b[?(@.v > 1)].v", []string{"$['b']['y']['v']", "$['b']['z']['v']"}, []interface{}{2.0, 3.0}},
+		{"$.c.n", []string{"$['c'][0]['n']", "$['c'][2]['n']"}, []interface{}{1.0, 3.0}},
+		{"$.c[5]", nil, nil},
+		{"$['b']['x']", []string{"$['b']['x']"}, []interface{}{map[string]interface{}{"v": 1.0}}},
+	}
+	for _, tcase := range tcases {
+		var locs []string
+		var values []interface{}
+		err := MustCompile(tcase.path).walk(data, func(loc location, v interface{}) error {
+			locs = append(locs, loc.String())
+			values = append(values, v)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tcase.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(locs, tcase.locs) || !reflect.DeepEqual(values, tcase.values) {
+			t.Errorf("%s: got %v %v", tcase.path, locs, values)
+		}
+	}
+
+	count := 0
+	MustCompile("$.c[*]").walk(data, func(loc location, v interface{}) error {
+		count++
+		return errStopWalk
+	})
+	if count != 1 {
+		t.Errorf("walk was not stopped: %d", count)
+	}
+}
+
+func Test_locationStringEscaping(t *testing.T) {
+	keys := []string{"a]b", "it's", "", "a\\b", "x.y", "['q']", "a\\'b", "\\"}
+	doc := map[string]interface{}{}
+	for i, key := range keys {
+		doc[key] = []interface{}{i}
+	}
+	for i, key := range keys {
+		path := location{key, 0}.String()
+		res, err := JsonPathLookup(doc, path)
+		if err != nil || res != i {
+			t.Errorf("%q: %s matched %v, %v", key, path, res, err)
+		}
+		var paths []string
+		MustCompile(path).walk(doc, func(loc location, v interface{}) error {
+			paths = append(paths, loc.String())
+			return nil
+		})
+		if !reflect.DeepEqual(paths, []string{path}) {
+			t.Errorf("%q: expected %s, got %v", key, path, paths)
+		}
+	}
+	if path := (location{"it's", "a\\b"}).String(); path != `$['it\'s']['a\\b']` {
+		t.Errorf("unexpected path %s", path)
+	}
+}